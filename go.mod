@@ -4,7 +4,6 @@ go 1.22.3
 
 require (
 	golang.org/x/crypto v0.23.0
+	golang.org/x/sys v0.20.0
 	golang.org/x/term v0.20.0
 )
-
-require golang.org/x/sys v0.20.0 // indirect
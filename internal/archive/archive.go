@@ -0,0 +1,166 @@
+package archive
+
+import (
+	"archive/tar"
+	"errors"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	walk "github.com/ShuaibKhan786/cipher-project/internal/walk"
+)
+
+const (
+	AbsolutePathErr     = "entry has an absolute path: "
+	PathTraversalErr    = "entry escapes the output directory: "
+	UnsupportedEntryErr = "unsupported entry type: "
+	DuplicateEntryErr   = "two files would have the same name in the archive: "
+	SymlinkEntryErr     = "entry goes through a symlink in the output directory: "
+
+	dirPerm = 0700
+)
+
+// Pack writes every entry as a tar stream, named by its path
+// relative to the root it was found under. w is not closed.
+func Pack(w io.Writer, entries []walk.Entry) error {
+	seen := make(map[string]bool, len(entries))
+	tw := tar.NewWriter(w)
+	for _, entry := range entries {
+		name := filepath.ToSlash(entry.Rel)
+		if seen[name] {
+			return errors.New(DuplicateEntryErr + name)
+		}
+		seen[name] = true
+		if err := addFile(tw, entry.Path, name); err != nil {
+			return err
+		}
+	}
+	return tw.Close()
+}
+
+func addFile(tw *tar.Writer, filename, name string) error {
+	file, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	hdr, err := tar.FileInfoHeader(info, "")
+	if err != nil {
+		return err
+	}
+	// owners mean nothing on the machine it is unpacked on
+	hdr.Name = name
+	hdr.Uid, hdr.Gid, hdr.Uname, hdr.Gname = 0, 0, "", ""
+	if err := tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	_, err = io.CopyN(tw, file, hdr.Size)
+	return err
+}
+
+// Unpack restores the files of a tar stream under root and returns
+// how many it wrote. Only regular files and directories are accepted,
+// and existing files are never overwritten.
+func Unpack(r io.Reader, root string) (int, error) {
+	tr := tar.NewReader(r)
+	count := 0
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return count, nil
+		}
+		if err != nil {
+			return count, err
+		}
+
+		target, err := EntryPath(root, hdr.Name)
+		if err != nil {
+			return count, err
+		}
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			err = os.MkdirAll(target, dirPerm)
+		case tar.TypeReg:
+			err = extractFile(tr, hdr, target)
+			count++
+		default:
+			err = errors.New(UnsupportedEntryErr + hdr.Name)
+		}
+		if err != nil {
+			return count, err
+		}
+	}
+}
+
+func extractFile(r io.Reader, hdr *tar.Header, target string) error {
+	if err := os.MkdirAll(filepath.Dir(target), dirPerm); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_EXCL, hdr.FileInfo().Mode().Perm())
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(file, r); err != nil {
+		file.Close()
+		os.Remove(target)
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	return os.Chtimes(target, hdr.ModTime, hdr.ModTime)
+}
+
+// EntryPath is where the entry name is written under root. Absolute
+// names and names climbing out of root with ".." are refused, on
+// every platform and with either kind of slash. So are names going
+// through a symlink already under root, which could point anywhere.
+func EntryPath(root, name string) (string, error) {
+	slashed := strings.ReplaceAll(name, `\`, "/")
+	if strings.HasPrefix(slashed, "/") || filepath.IsAbs(name) || filepath.VolumeName(name) != "" || hasDrive(slashed) {
+		return "", errors.New(AbsolutePathErr + name)
+	}
+	clean := path.Clean(slashed)
+	if clean == ".." || strings.HasPrefix(clean, "../") {
+		return "", errors.New(PathTraversalErr + name)
+	}
+	if err := noSymlinks(root, clean); err != nil {
+		return "", err
+	}
+	return filepath.Join(root, filepath.FromSlash(clean)), nil
+}
+
+// noSymlinks checks every path of root leading to the slash separated
+// name with Lstat, down to the first one which doesn't exist yet.
+// Root itself is the user's choice and may be a symlink.
+func noSymlinks(root, name string) error {
+	current := root
+	for _, part := range strings.Split(name, "/") {
+		current = filepath.Join(current, part)
+		info, err := os.Lstat(current)
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if info.Mode()&os.ModeSymlink != 0 {
+			return errors.New(SymlinkEntryErr + name)
+		}
+	}
+	return nil
+}
+
+// hasDrive catches "C:..." names, which filepath only knows about on
+// Windows.
+func hasDrive(name string) bool {
+	return len(name) >= 2 && name[1] == ':' &&
+		(name[0] >= 'a' && name[0] <= 'z' || name[0] >= 'A' && name[0] <= 'Z')
+}
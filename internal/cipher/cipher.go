@@ -4,26 +4,55 @@ import (
 	"bufio"
 	"crypto/aes"
 	"crypto/cipher"
+	"encoding/binary"
+	"errors"
+	"fmt"
 	"io"
 	"os"
+	"path/filepath"
 	"sync"
 
 	cliarg "github.com/ShuaibKhan786/cipher-project/internal/cmdlineargs"
+	header "github.com/ShuaibKhan786/cipher-project/internal/header"
 	salting "github.com/ShuaibKhan786/cipher-project/internal/salting"
+	secbuf "github.com/ShuaibKhan786/cipher-project/internal/secbuf"
 )
 
+// Key is shared by every copy of the metadata, whoever starts the
+// cipher destroys it once the file is done.
 type DecryptionMetadata struct {
 	Filename string
-	Key      []byte
+	Output   string
+	Force    bool
+	Key      *secbuf.Buffer
 	Nonce    salting.Nonce
 	SeekSize int64
 }
 
+// Key is the random data key of the file, Header carries the
+// nonce and the key slots which wrap that data key. Output defaults
+// to the file next to Filename, an existing one is only overwritten
+// with Force.
 type EncryptionMetadata struct {
 	Filename string
-	Key      []byte
-	Nonce    salting.Nonce
-	Salt     salting.Salt
+	Output   string
+	Force    bool
+	Key      *secbuf.Buffer
+	Header   *header.Header
+}
+
+// ChunkError is the chunk, counted from 0, where reading failed.
+type ChunkError struct {
+	Chunk uint64
+	Err   error
+}
+
+func (e *ChunkError) Error() string {
+	return fmt.Sprintf("%v at chunk %d", e.Err, e.Chunk)
+}
+
+func (e *ChunkError) Unwrap() error {
+	return e.Err
 }
 
 type FilePair struct {
@@ -31,30 +60,58 @@ type FilePair struct {
 	Wfile *os.File
 }
 
+// Done is sent once a file is finished, whether it failed or not.
+// Read is how much of the Size bytes of the input was consumed.
 type CipherProgress struct {
-	Filename string
+	Filename   string
 	Percentage float64
+	Read       int64
+	Size       int64
+	Done       bool
 }
 
 type MdProgressTracker struct {
 	Tracker bool
-	Fpair FilePair
+	Fpair   FilePair
 }
 
 type GlobalProgressTracker struct {
-	Mu sync.Mutex
+	Mu      sync.Mutex
 	Tracker map[string]MdProgressTracker
 }
 
+// Every chunk is sealed with its own nonce, the header nonce XORed
+// with the chunk counter, and the final chunk is marked through the
+// additional data so a truncated file fails to authenticate.
 const (
 	ChunkSize = 1024 * 1024
+	lastChunk = 1
+
+	TruncatedErr      = "encrypted file is truncated"
+	AuthenticationErr = "data corrupted"
+	OutputExistsErr   = "the output already exists, " + cliarg.ForceOpt + " overwrites it"
+
+	outputDirPerm = 0700
+)
+
+// ErrAuthentication is a chunk which doesn't open, ErrTruncated a
+// file missing its final chunk or cut inside one. The data key was checked by its key
+// slot, so a chunk which doesn't open was modified. Both come in a
+// *ChunkError, and Encryption and Decryption wrap every error in an
+// *os.PathError naming the file.
+var (
+	ErrAuthentication = errors.New(AuthenticationErr)
+	ErrTruncated      = errors.New(TruncatedErr)
+	// ErrOutputExists is an output left alone, it comes in an
+	// *os.PathError naming the output.
+	ErrOutputExists = errors.New(OutputExistsErr)
 )
 
 func InitGlobalProgressTracker(fileNames []string) *GlobalProgressTracker {
 	gtracker := &GlobalProgressTracker{
 		Tracker: make(map[string]MdProgressTracker),
 	}
-	
+
 	gtracker.Mu.Lock()
 	defer gtracker.Mu.Unlock()
 	for _, filename := range fileNames {
@@ -63,15 +120,21 @@ func InitGlobalProgressTracker(fileNames []string) *GlobalProgressTracker {
 	return gtracker
 }
 
-func Encryption(md EncryptionMetadata,c chan<- CipherProgress,tracker *GlobalProgressTracker) error {
-	filepair, err := openCreate(md.Filename, cliarg.EncryptionOp)
+func Encryption(md EncryptionMetadata, c chan<- CipherProgress, tracker *GlobalProgressTracker) error {
+	return fileError("encrypt", md.Filename, encryption(md, c, tracker))
+}
+
+func encryption(md EncryptionMetadata, c chan<- CipherProgress, tracker *GlobalProgressTracker) error {
+	output := outputName(md.Filename, md.Output, cliarg.EncryptionOp)
+	filepair, err := openCreate(md.Filename, output, md.Force)
 	if err != nil {
 		return err
 	}
 	defer func() {
 		fileClose(filepair)
 		tracker.Mu.Lock()
-		tracker.Tracker[md.Filename] = MdProgressTracker{Fpair: FilePair{Rfile: nil,Wfile: nil}}
+		done := tracker.Tracker[md.Filename].Tracker
+		tracker.Tracker[md.Filename] = MdProgressTracker{Tracker: done}
 		tracker.Mu.Unlock()
 	}()
 
@@ -80,28 +143,23 @@ func Encryption(md EncryptionMetadata,c chan<- CipherProgress,tracker *GlobalPro
 	tracker.Mu.Unlock()
 
 	filestat, err := filepair.Rfile.Stat()
-	if  err != nil{
+	if err != nil {
 		fileClose(filepair)
-		os.Remove(md.Filename + cliarg.EncryptedFileExt)
+		os.Remove(output)
 		return err
 	}
 	totalFileSize := float64(filestat.Size())
 
-	if _, err := filepair.Wfile.Write(md.Salt); err != nil {
+	if err := md.Header.Write(filepair.Wfile); err != nil {
 		fileClose(filepair)
-		os.Remove(md.Filename + cliarg.EncryptedFileExt)
-		return err
-	}
-	if _, err := filepair.Wfile.Write(md.Nonce); err != nil {
-		fileClose(filepair)
-		os.Remove(md.Filename + cliarg.EncryptedFileExt)
+		os.Remove(output)
 		return err
 	}
 
-	gcm, err := newgcm(md.Key)
+	gcm, err := newgcm(md.Key.Bytes())
 	if err != nil {
 		fileClose(filepair)
-		os.Remove(md.Filename + cliarg.EncryptedFileExt)
+		os.Remove(output)
 		return err
 	}
 
@@ -111,28 +169,41 @@ func Encryption(md EncryptionMetadata,c chan<- CipherProgress,tracker *GlobalPro
 	buffer := make([]byte, ChunkSize)
 
 	var currentRead float64
+	var counter uint64
 	for {
-		n, err := rBuffer.Read(buffer)
-		if err != nil && err != io.EOF {
+		n, err := io.ReadFull(rBuffer, buffer)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
 			fileClose(filepair)
-			os.Remove(md.Filename + cliarg.EncryptedFileExt)
+			os.Remove(output)
 			return err
 		}
 
-		if n == 0 {
-			break
+		last, err := isLastChunk(rBuffer)
+		if err != nil {
+			fileClose(filepair)
+			os.Remove(output)
+			return err
 		}
 
-		cipherText := gcm.Seal(nil, md.Nonce, buffer[:n], nil)
+		cipherText := gcm.Seal(nil, chunkNonce(md.Header.Nonce, counter), buffer[:n], chunkAD(last))
 
 		if _, err = wBuffer.Write(cipherText); err != nil {
 			fileClose(filepair)
-			os.Remove(md.Filename + cliarg.EncryptedFileExt)
+			os.Remove(output)
 			return err
 		}
+		counter++
 		currentRead += float64(n)
-		percentage := (currentRead / totalFileSize) * 100.00
-		c <- CipherProgress{Filename: md.Filename,Percentage: percentage}
+		c <- CipherProgress{Filename: md.Filename, Percentage: percentage(currentRead, totalFileSize), Read: int64(currentRead), Size: int64(totalFileSize)}
+
+		if last {
+			break
+		}
+	}
+	if err := wBuffer.Flush(); err != nil {
+		fileClose(filepair)
+		os.Remove(output)
+		return err
 	}
 	tracker.Mu.Lock()
 	tracker.Tracker[md.Filename] = MdProgressTracker{Tracker: true}
@@ -140,17 +211,22 @@ func Encryption(md EncryptionMetadata,c chan<- CipherProgress,tracker *GlobalPro
 	return nil
 }
 
-func Decryption(md DecryptionMetadata,c chan<- CipherProgress, tracker *GlobalProgressTracker) error {
-	cachedFilename := md.Filename[:len(md.Filename)-len(cliarg.EncryptedFileExt)]
+func Decryption(md DecryptionMetadata, c chan<- CipherProgress, tracker *GlobalProgressTracker) error {
+	return fileError("decrypt", md.Filename, decryption(md, c, tracker))
+}
+
+func decryption(md DecryptionMetadata, c chan<- CipherProgress, tracker *GlobalProgressTracker) error {
+	cachedFilename := outputName(md.Filename, md.Output, cliarg.DecryptionOp)
 
-	filepair, err := openCreate(md.Filename, cliarg.DecryptionOp)
+	filepair, err := openCreate(md.Filename, cachedFilename, md.Force)
 	if err != nil {
 		return err
 	}
 	defer func() {
 		fileClose(filepair)
 		tracker.Mu.Lock()
-		tracker.Tracker[md.Filename] = MdProgressTracker{Fpair: FilePair{Rfile: nil,Wfile: nil}}
+		done := tracker.Tracker[md.Filename].Tracker
+		tracker.Tracker[md.Filename] = MdProgressTracker{Tracker: done}
 		tracker.Mu.Unlock()
 	}()
 
@@ -159,20 +235,20 @@ func Decryption(md DecryptionMetadata,c chan<- CipherProgress, tracker *GlobalPr
 	tracker.Mu.Unlock()
 
 	filestat, err := filepair.Rfile.Stat()
-	if  err != nil{
+	if err != nil {
 		fileClose(filepair)
 		os.Remove(cachedFilename)
 		return err
 	}
 	totalFileSize := float64(filestat.Size())
 
-	if _,err = filepair.Rfile.Seek(md.SeekSize,io.SeekStart); err != nil {
+	if _, err = filepair.Rfile.Seek(md.SeekSize, io.SeekStart); err != nil {
 		fileClose(filepair)
 		os.Remove(cachedFilename)
 		return err
 	}
 
-	gcm, err := newgcm(md.Key)
+	gcm, err := newgcm(md.Key.Bytes())
 	if err != nil {
 		fileClose(filepair)
 		os.Remove(cachedFilename)
@@ -184,34 +260,51 @@ func Decryption(md DecryptionMetadata,c chan<- CipherProgress, tracker *GlobalPr
 	defer wBuffer.Flush()
 	buffer := make([]byte, ChunkSize+gcm.Overhead())
 
-	var currentRead float64
+	currentRead := float64(md.SeekSize)
+	var counter uint64
 	for {
-		n, err := rBuffer.Read(buffer)
-		if err != nil && err != io.EOF {
+		n, err := io.ReadFull(rBuffer, buffer)
+		if err == io.EOF {
+			// the final chunk never showed up
+			err = &ChunkError{Chunk: counter, Err: ErrTruncated}
+		}
+		if err != nil && err != io.ErrUnexpectedEOF {
 			fileClose(filepair)
 			os.Remove(cachedFilename)
 			return err
 		}
 
-		if n == 0 {
-			break
-		}
-		
-		plainText, err := gcm.Open(nil, md.Nonce, buffer[:n], nil)
+		last, err := isLastChunk(rBuffer)
 		if err != nil {
 			fileClose(filepair)
 			os.Remove(cachedFilename)
 			return err
 		}
 
+		plainText, err := openChunk(gcm, nil, chunkNonce(md.Nonce, counter), buffer[:n], last, true)
+		if err != nil {
+			fileClose(filepair)
+			os.Remove(cachedFilename)
+			return &ChunkError{Chunk: counter, Err: err}
+		}
+
 		if _, err = wBuffer.Write(plainText); err != nil {
 			fileClose(filepair)
 			os.Remove(cachedFilename)
 			return err
 		}
+		counter++
 		currentRead += float64(n)
-		percentage := (currentRead / totalFileSize) * 100.00
-		c <- CipherProgress{Filename: md.Filename,Percentage: percentage}
+		c <- CipherProgress{Filename: md.Filename, Percentage: percentage(currentRead, totalFileSize), Read: int64(currentRead), Size: int64(totalFileSize)}
+
+		if last {
+			break
+		}
+	}
+	if err := wBuffer.Flush(); err != nil {
+		fileClose(filepair)
+		os.Remove(cachedFilename)
+		return err
 	}
 	tracker.Mu.Lock()
 	tracker.Tracker[md.Filename] = MdProgressTracker{Tracker: true}
@@ -219,17 +312,92 @@ func Decryption(md DecryptionMetadata,c chan<- CipherProgress, tracker *GlobalPr
 	return nil
 }
 
-func openCreate(filename, op string) (FilePair, error) {
+// Verify opens every chunk of an encrypted file with the key of md
+// without writing anything, Output is not used. The progress is
+// not sent when c is nil.
+func Verify(md DecryptionMetadata, c chan<- CipherProgress) error {
+	return fileError("verify", md.Filename, verify(md, c))
+}
+
+func verify(md DecryptionMetadata, c chan<- CipherProgress) error {
+	file, err := os.Open(md.Filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	filestat, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	totalFileSize := float64(filestat.Size())
+
+	if _, err = file.Seek(md.SeekSize, io.SeekStart); err != nil {
+		return err
+	}
+	reader, err := NewReader(file, md.Key, md.Nonce)
+	if err != nil {
+		return err
+	}
+
+	buffer := make([]byte, ChunkSize)
+	currentRead := float64(md.SeekSize)
+	for {
+		n, err := io.ReadFull(reader, buffer)
+		if err == io.EOF {
+			if c != nil {
+				c <- CipherProgress{Filename: md.Filename, Percentage: 100, Read: int64(totalFileSize), Size: int64(totalFileSize)}
+			}
+			return nil
+		}
+		if err != nil && err != io.ErrUnexpectedEOF {
+			return err
+		}
+		currentRead += float64(n)
+		if c != nil {
+			c <- CipherProgress{Filename: md.Filename, Percentage: min(percentage(currentRead, totalFileSize), 100), Read: int64(min(currentRead, totalFileSize)), Size: int64(totalFileSize)}
+		}
+	}
+}
+
+// fileError names the file err happened to, unless an *os.PathError
+// already does.
+func fileError(op, filename string, err error) error {
+	var pathErr *os.PathError
+	if err == nil || errors.As(err, &pathErr) {
+		return err
+	}
+	return &os.PathError{Op: op, Path: filename, Err: err}
+}
+
+func outputName(filename, output, op string) string {
+	if output != "" {
+		return output
+	}
+	return cliarg.OutputName(filename, op)
+}
+
+// openCreate opens filename and creates output, an existing output
+// is refused unless force is set. Only an output created here may
+// be removed on failure.
+func openCreate(filename, output string, force bool) (FilePair, error) {
 	Rfile, err := os.Open(filename)
 	if err != nil {
 		return FilePair{}, err
 	}
 
-	var Wfile *os.File
-	if op == cliarg.EncryptionOp {
-		Wfile, err = os.Create(filename + cliarg.EncryptedFileExt)
-	} else {
-		Wfile, err = os.Create(filename[:len(filename)-len(cliarg.EncryptedFileExt)])
+	// a mirrored tree is created as it is filled
+	if err := os.MkdirAll(filepath.Dir(output), outputDirPerm); err != nil {
+		Rfile.Close()
+		return FilePair{}, err
+	}
+	flag := os.O_RDWR | os.O_CREATE | os.O_EXCL
+	if force {
+		flag = os.O_RDWR | os.O_CREATE | os.O_TRUNC
+	}
+	Wfile, err := os.OpenFile(output, flag, 0666)
+	if errors.Is(err, os.ErrExist) {
+		err = &os.PathError{Op: "create", Path: output, Err: ErrOutputExists}
 	}
 	if err != nil {
 		Rfile.Close()
@@ -260,3 +428,62 @@ func newgcm(key []byte) (cipher.AEAD, error) {
 	}
 	return gcm, nil
 }
+
+// isLastChunk reports whether the reader has nothing left after
+// the chunk that was just read.
+func isLastChunk(r *bufio.Reader) (bool, error) {
+	_, err := r.Peek(1)
+	if err == io.EOF {
+		return true, nil
+	}
+	return false, err
+}
+
+func chunkNonce(nonce salting.Nonce, counter uint64) []byte {
+	chunk := make([]byte, len(nonce))
+	copy(chunk, nonce)
+	var ctr [8]byte
+	binary.BigEndian.PutUint64(ctr[:], counter)
+	for i, v := range ctr {
+		chunk[len(chunk)-len(ctr)+i] ^= v
+	}
+	return chunk
+}
+
+// openChunk opens the chunk sealed with nonce. A full chunk found at
+// the end which opens as a middle chunk means the file was cut right
+// after it. It is tried first, a failed Open wipes dst and dst may
+// share the chunk. A short chunk at the end which doesn't open came
+// up short of the bytes it was sealed with when the stream may be
+// cut: the file was cut inside it. The file length isn't kept
+// anywhere, so changed bytes in that chunk are reported the same way.
+func openChunk(gcm cipher.AEAD, dst, nonce, chunk []byte, last, mayBeCut bool) ([]byte, error) {
+	full := len(chunk) == ChunkSize+gcm.Overhead()
+	if last && full {
+		if _, err := gcm.Open(nil, nonce, chunk, chunkAD(false)); err == nil {
+			return nil, ErrTruncated
+		}
+	}
+	plain, err := gcm.Open(dst, nonce, chunk, chunkAD(last))
+	if err != nil && last && !full && mayBeCut {
+		return nil, ErrTruncated
+	}
+	if err != nil {
+		return nil, ErrAuthentication
+	}
+	return plain, nil
+}
+
+func chunkAD(last bool) []byte {
+	if last {
+		return []byte{lastChunk}
+	}
+	return []byte{0}
+}
+
+func percentage(current, total float64) float64 {
+	if total == 0 {
+		return 100.00
+	}
+	return (current / total) * 100.00
+}
@@ -0,0 +1,168 @@
+package aescipher
+
+import (
+	"bufio"
+	"crypto/cipher"
+	"errors"
+	"io"
+
+	header "github.com/ShuaibKhan786/cipher-project/internal/header"
+	secbuf "github.com/ShuaibKhan786/cipher-project/internal/secbuf"
+)
+
+const WriterClosedErr = "write to a closed encryption stream"
+
+// Writer encrypts a stream of unknown length in the same format as
+// Encryption, the header followed by sealed chunks. A full chunk is
+// held back until more data comes, so Close can mark the final one.
+type Writer struct {
+	w       io.Writer
+	gcm     cipher.AEAD
+	nonce   []byte
+	buffer  []byte
+	counter uint64
+	closed  bool
+}
+
+// NewWriter writes hdr to w and returns a Writer sealing with key,
+// the data key wrapped in hdr.
+func NewWriter(w io.Writer, key *secbuf.Buffer, hdr *header.Header) (*Writer, error) {
+	if err := hdr.Write(w); err != nil {
+		return nil, err
+	}
+	return NewStreamWriter(w, key, hdr.Nonce)
+}
+
+// NewStreamWriter writes the chunks only, for several streams sealed
+// with the same key in one file. Every stream needs its own nonce.
+func NewStreamWriter(w io.Writer, key *secbuf.Buffer, nonce []byte) (*Writer, error) {
+	gcm, err := newgcm(key.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	return &Writer{
+		w:      w,
+		gcm:    gcm,
+		nonce:  nonce,
+		buffer: make([]byte, 0, ChunkSize),
+	}, nil
+}
+
+func (w *Writer) Write(p []byte) (int, error) {
+	if w.closed {
+		return 0, errors.New(WriterClosedErr)
+	}
+	written := 0
+	for len(p) > 0 {
+		if len(w.buffer) == ChunkSize {
+			if err := w.seal(false); err != nil {
+				return written, err
+			}
+		}
+		n := copy(w.buffer[len(w.buffer):ChunkSize], p)
+		w.buffer = w.buffer[:len(w.buffer)+n]
+		p = p[n:]
+		written += n
+	}
+	return written, nil
+}
+
+// Close seals the final chunk, it doesn't close the underlying
+// writer.
+func (w *Writer) Close() error {
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+	err := w.seal(true)
+	secbuf.Wipe(w.buffer[:cap(w.buffer)])
+	return err
+}
+
+func (w *Writer) seal(last bool) error {
+	cipherText := w.gcm.Seal(nil, chunkNonce(w.nonce, w.counter), w.buffer, chunkAD(last))
+	w.counter++
+	w.buffer = w.buffer[:0]
+	_, err := w.w.Write(cipherText)
+	return err
+}
+
+// Reader decrypts what a Writer or Encryption produced, r must be
+// positioned right after the header. Every chunk is authenticated
+// before any of it is returned, and a missing final chunk is an
+// error instead of a clean end of file.
+type Reader struct {
+	r       *bufio.Reader
+	gcm     cipher.AEAD
+	nonce   []byte
+	buffer  []byte
+	plain   []byte
+	counter uint64
+	done    bool
+	// sized is a stream whose length is known, it can't be cut
+	sized bool
+}
+
+func NewReader(r io.Reader, key *secbuf.Buffer, nonce []byte) (*Reader, error) {
+	gcm, err := newgcm(key.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	return &Reader{
+		r:      bufio.NewReader(r),
+		gcm:    gcm,
+		nonce:  nonce,
+		buffer: make([]byte, ChunkSize+gcm.Overhead()),
+	}, nil
+}
+
+// NewSectionReader is NewReader for a stream which is exactly the
+// section, its length coming from authenticated data such as a vault
+// index. A short final chunk which doesn't open was then changed
+// rather than cut.
+func NewSectionReader(r *io.SectionReader, key *secbuf.Buffer, nonce []byte) (*Reader, error) {
+	reader, err := NewReader(r, key, nonce)
+	if err != nil {
+		return nil, err
+	}
+	reader.sized = true
+	return reader, nil
+}
+
+func (r *Reader) Read(p []byte) (int, error) {
+	for len(r.plain) == 0 {
+		if r.done {
+			return 0, io.EOF
+		}
+		if err := r.open(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, r.plain)
+	r.plain = r.plain[n:]
+	return n, nil
+}
+
+func (r *Reader) open() error {
+	n, err := io.ReadFull(r.r, r.buffer)
+	if err == io.EOF {
+		// the final chunk never showed up
+		return &ChunkError{Chunk: r.counter, Err: ErrTruncated}
+	}
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return err
+	}
+
+	last, err := isLastChunk(r.r)
+	if err != nil {
+		return err
+	}
+	plain, err := openChunk(r.gcm, r.buffer[:0], chunkNonce(r.nonce, r.counter), r.buffer[:n], last, !r.sized)
+	if err != nil {
+		return &ChunkError{Chunk: r.counter, Err: err}
+	}
+	r.counter++
+	r.plain = plain
+	r.done = last
+	return nil
+}
@@ -2,75 +2,746 @@ package cmdlineargs
 
 import (
 	"errors"
+	"io"
 	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
 
-    esccode "github.com/ShuaibKhan786/cipher-project/internal/escapecode" 
+	esccode "github.com/ShuaibKhan786/cipher-project/internal/escapecode"
+	passphrase "github.com/ShuaibKhan786/cipher-project/internal/passphrase"
+	shred "github.com/ShuaibKhan786/cipher-project/internal/shred"
+	walk "github.com/ShuaibKhan786/cipher-project/internal/walk"
 )
 
 const (
-	EncryptionOp = "-e"
-	DecryptionOp = "-d"
-	InvalidOpErr = "invalid operation"
-	InvalidFilenamesErr = "one or more filenames doesn't exist"
-	InvalidDeExtErr = "invalid file extention \nfiles must end with (.enc) file for decryption"
-	InvalidEnExtErr = "invalid file extention \nfiles must not end with (.enc) file for encryption"
-	MinimumNumberOfArgs = 3
-	EncryptedFileExt = ".enc"
-	NoArgs = esccode.Green+"EncryptEase is a file cipher utilizing AES in GCM mode, with key derivation handled by Argon2d." +
-	esccode.Blue+"\n\nYou can encrypt or decrypt single or multiple files using a secure password." +
-	esccode.Red+"\n\nPlease use a strong and memorable password." +
-	esccode.Yellow+"\n\tEncryption: EncryptEase -e your-filenames" +
-	"\n\tDecryption: EncryptEase -d your-filenames.enc"+
-	esccode.Reset
+	EncryptionOp          = "encrypt"
+	DecryptionOp          = "decrypt"
+	VerifyOp              = "verify"
+	InspectOp             = "inspect"
+	RekeyOp               = "rekey"
+	MigrateOp             = "migrate"
+	HelpOp                = "help"
+	HelpOpt               = "--help"
+	AddSlotOp             = "add-slot"
+	RemoveSlotOp          = "remove-slot"
+	ListSlotsOp           = "list-slots"
+	KeygenOp              = "keygen"
+	GeneratePassphraseOp  = "generate-passphrase"
+	PackOp                = "pack"
+	UnpackOp              = "unpack"
+	VaultOp               = "vault"
+	VaultCreate           = "create"
+	VaultAdd              = "add"
+	VaultList             = "list"
+	VaultExtract          = "extract"
+	VaultRemove           = "remove"
+	VaultCompact          = "compact"
+	RecipientOpt          = "--recipient"
+	IdentityOpt           = "--identity"
+	KeyfileOpt            = "--keyfile"
+	NoPasswordOpt         = "--no-password"
+	PasswordOpt           = "--password"
+	PasswordFileOpt       = "--password-file"
+	PasswordFDOpt         = "--password-fd"
+	PasswordEnvOpt        = "--password-env"
+	PasswordCommandOpt    = "--password-command"
+	PasswordStdinOpt      = "--password-stdin"
+	MinScoreOpt           = "--min-score"
+	GeneratePassphraseOpt = "--generate-passphrase"
+	WordsOpt              = "--words"
+	RecursiveOpt          = "-r"
+	RecursiveLongOpt      = "--recursive"
+	SymlinksOpt           = "--symlinks"
+	SpecialOpt            = "--special"
+	HiddenOpt             = "--hidden"
+	OutputOpt             = "-o"
+	OutputDirOpt          = "--output-dir"
+	KeepPathsOpt          = "--keep-paths"
+	// OutputRootOpt is the older spelling of OutputDirOpt with
+	// KeepPathsOpt.
+	OutputRootOpt        = "--output-root"
+	ForceOpt             = "--force"
+	FilesFromOpt         = "--files-from"
+	NullSeparatorOpt     = "-0"
+	IncludeOpt           = "--include"
+	ExcludeOpt           = "--exclude"
+	StdinName            = "-"
+	JSONOpt              = "--json"
+	ColorOpt             = "--color"
+	ShredOpt             = "--shred"
+	DeleteOriginalsOpt   = "--delete-originals"
+	KeepOriginalsOpt     = "--keep-originals"
+	DeleteEncryptedOpt   = "--delete-encrypted"
+	PassesOpt            = "--passes"
+	JobsOpt              = "-j"
+	JobsLongOpt          = "--jobs"
+	DefaultMinScore      = 2
+	MaxScore             = 4
+	InvalidOpErr         = "invalid operation"
+	UnknownCommandErr    = "unknown command, EncryptEase help lists them: "
+	NoFilesErr           = "no files given, EncryptEase help shows the usage of: "
+	UnsupportedOptionErr = "option not supported by this command, see its " + HelpOpt + ": "
+	InvalidFilenamesErr  = "one or more filenames doesn't exist"
+	InvalidDeExtErr      = "invalid file extention \nfiles must end with (.enc) file for decryption or key management"
+	InvalidEnExtErr      = "invalid file extention \nfiles must not end with (.enc) file for encryption"
+	InvalidOptionErr     = "unknown option or option without a value: "
+	RecipientOptErr      = RecipientOpt + " can only be used for encryption"
+	IdentityOptErr       = IdentityOpt + " can only be used for decryption, unpacking, opening a vault and key slot management"
+	PackArgsErr          = "pack takes the archive name followed by the files and directories to put in it"
+	ArchiveExistsErr     = "the archive already exists: "
+	VaultActionErr       = "vault takes create, add, list, extract, remove or compact, followed by the vault name"
+	VaultArgsErr         = "wrong number of names for vault "
+	KeygenArgsErr        = "keygen takes exactly one filename for the private key"
+	NoPasswordOptErr     = NoPasswordOpt + " can only be used for encryption with " + KeyfileOpt + " or " + RecipientOpt
+	PasswordOptErr       = PasswordOpt + " is refused, a password on the command line is visible to other users and kept in shell history" +
+		"\nuse " + PasswordFileOpt + ", " + PasswordFDOpt + ", " + PasswordEnvOpt + ", " + PasswordCommandOpt + " or " + PasswordStdinOpt + " instead"
+	PasswordSourcesErr = "only one password source can be given"
+	PasswordFDErr      = PasswordFDOpt + " must be a file descriptor number"
+	MinScoreErr        = MinScoreOpt + " must be a number from 0 (accept anything but empty) to 4 (strong)"
+	WordsErr           = WordsOpt + " must be a number of words between 4 and 20"
+	OutputDirOptErr    = OutputDirOpt + " can only be used for encryption, decryption, unpacking and vault extract"
+	KeepPathsOptErr    = KeepPathsOpt + " can only be used with " + OutputDirOpt
+	OutputCollisionErr = "two files would be written to the same output: "
+	WalkPolicyErr      = "invalid policy: "
+	DirectoryErr       = "is a directory, use " + RecursiveOpt + " to walk it: "
+	NoFilesFoundErr    = "no files found"
+	FilesFromOptErr    = FilesFromOpt + " can only be used with operations taking files"
+	FilesFromStdinErr  = FilesFromOpt + " " + StdinName + " takes stdin, nothing can be asked on the terminal" +
+		"\ngive the password with " + PasswordFileOpt + ", " + PasswordFDOpt + ", " + PasswordEnvOpt + " or " + PasswordCommandOpt
+	NullSeparatorOptErr       = NullSeparatorOpt + " can only be used with " + FilesFromOpt
+	JSONPromptErr             = JSONOpt + " asks nothing, " + GeneratePassphraseOpt + " needs the terminal"
+	JSONPasswordErr           = JSONOpt + " asks nothing, give the password with " + PasswordFileOpt + ", " + PasswordFDOpt + ", " + PasswordEnvOpt + ", " + PasswordCommandOpt + " or " + PasswordStdinOpt
+	ColorErr                  = ColorOpt + " must be " + esccode.ColorAuto + ", " + esccode.ColorAlways + " or " + esccode.ColorNever
+	PassesErr                 = PassesOpt + " must be a number of passes from 1 to 35"
+	PassesOptErr              = PassesOpt + " can only be used with " + ShredOpt
+	OriginalsOptErr           = DeleteOriginalsOpt + " and " + KeepOriginalsOpt + " can't be used together"
+	ShredKeepErr              = ShredOpt + " removes the originals, it can't be used with " + KeepOriginalsOpt
+	JobsErr                   = JobsLongOpt + " must be a number of files processed at once, at least 1"
+	FilterOptErr              = IncludeOpt + " and " + ExcludeOpt + " can only be used with operations taking files"
+	GeneratePassphraseArgsErr = GeneratePassphraseOp + " takes no filename"
+	GeneratePassphraseOptErr  = GeneratePassphraseOpt + " can only be used for encryption with a password typed on the terminal"
+	MinimumNumberOfArgs       = 3
+	EncryptedFileExt          = ".enc"
 )
 
+// noArgs is the overview shown without a command. It is built when
+// shown, once the colors are on or off.
+func noArgs() string {
+	return esccode.Green + "EncryptEase is a file cipher utilizing AES in GCM mode, with key derivation handled by Argon2d." +
+		esccode.Blue + "\n\nYou can encrypt or decrypt single or multiple files using a secure password." +
+		esccode.Red + "\n\nPlease use a strong and memorable password." +
+		esccode.Yellow + "\n\tEncryption: EncryptEase encrypt your-filenames" +
+		"\n\tDecryption: EncryptEase decrypt your-filenames.enc" +
+		"\n\tCheck without decrypting: EncryptEase verify your-filenames.enc" +
+		"\n\tHeader and key slots: EncryptEase inspect your-filenames.enc" +
+		"\n\tChange password: EncryptEase rekey your-filenames.enc" +
+		"\n\tKey slots: EncryptEase add-slot|remove-slot|list-slots your-filenames.enc" +
+		"\n\tKey pair: EncryptEase keygen your-keyname" +
+		"\n\tEncryption for a public key: EncryptEase encrypt --recipient your-keyname.pub your-filenames" +
+		"\n\tDecryption with a private key: EncryptEase decrypt --identity your-keyname your-filenames.enc" +
+		"\n\tPassword and keyfile: EncryptEase encrypt --keyfile your-keyfile your-filenames" +
+		"\n\tKeyfile only: EncryptEase encrypt --keyfile your-keyfile --no-password your-filenames" +
+		"\n\tDirectories: EncryptEase encrypt|decrypt -r [-o dir [--keep-paths]] [--symlinks skip|follow|error] [--special skip|error] [--hidden include|skip] your-directories" +
+		"\n\tParallel files: -j|--jobs n (default the number of CPUs) files are encrypted or decrypted at once" +
+		"\n\tFile lists: --files-from path (- for stdin) [-0] reads the filenames from a file, one per line or NUL separated with -0" +
+		"\n\tFilters: --include pattern | --exclude pattern, repeatable globs matched against the name, or the path when they have a /" +
+		"\n\tArchive: EncryptEase pack your-archive.enc your-files-and-directories" +
+		"\n\tArchive extraction: EncryptEase unpack [-o dir] your-archive.enc" +
+		"\n\tVault: EncryptEase vault create|list|compact your-vault.enc" +
+		"\n\t       EncryptEase vault add your-vault.enc your-files-and-directories" +
+		"\n\t       EncryptEase vault extract [-o dir] your-vault.enc [entry-names]" +
+		"\n\t       EncryptEase vault remove your-vault.enc entry-names" +
+		"\n\tPassphrase: EncryptEase generate-passphrase [--words n] or EncryptEase encrypt --generate-passphrase [--words n] your-filenames" +
+		"\n\tPassword strength: --min-score 0-4 (default 2), weaker passwords are refused for encryption" +
+		"\n\tPassword without a terminal: --password-file path | --password-fd n | --password-env name | --password-command cmd | --password-stdin" +
+		"\n\tDefaults from the config files: EncryptEase config show [command]" +
+		"\n\tColors: --color auto|always|never, auto colors a terminal unless NO_COLOR is set" +
+		"\n\tCommands and their options: EncryptEase help, EncryptEase your-command --help" +
+		esccode.Reset
+}
+
 type ArgsMetaData struct {
-    FileNames  []string
-	NumOfFiles int
-    Operation  string
+	FileNames          []string
+	NumOfFiles         int
+	Operation          string
+	Recipients         []string
+	Identity           string
+	Keyfile            string
+	NoPassword         bool
+	Password           PasswordSource
+	MinScore           int
+	GeneratePassphrase bool
+	Words              int
+	Recursive          bool
+	Walk               walk.Policy
+	// OutputDir is where the outputs go instead of next to their
+	// input, under their relative paths with KeepPaths.
+	OutputDir string
+	KeepPaths bool
+	// Force overwrites outputs which already exist instead of
+	// failing those files.
+	Force bool
+	// Outputs maps an input file to its output file when it is not
+	// written next to the input.
+	Outputs map[string]string
+	// VaultAction is the vault subcommand, FileNames then holds the
+	// vault only and VaultArgs the names after it.
+	VaultAction string
+	VaultArgs   []string
+	// FilesFrom is a file listing more filenames, or StdinName,
+	// one per line or NUL separated with NullSeparated.
+	FilesFrom     string
+	NullSeparated bool
+	// JSON writes newline-delimited JSON events instead of text,
+	// and never prompts.
+	JSON bool
+	// Shred overwrites the originals Passes times before removing
+	// them.
+	Shred  bool
+	Passes int
+	// DeleteOriginals and KeepOriginals answer for the user whether
+	// the originals of an encryption are removed, DeleteEncrypted
+	// removes the files a decryption opened.
+	DeleteOriginals bool
+	KeepOriginals   bool
+	DeleteEncrypted bool
+	// Color is the color mode, auto when empty.
+	Color string
+	// ConfigFiles are the config files read, user file first.
+	ConfigFiles []string
+	// Help asks for the --help text of the command instead of
+	// running it.
+	Help bool
+	// Jobs is the number of files encrypted or decrypted at once.
+	Jobs int
+
+	invalidOptions []string
+	givenOptions   []string
+	// sources are the config lines which set an option
+	sources         map[string]string
+	configErr       error
+	filesFromErr    error
+	passwordRefused bool
+	passwordSources int
+}
+
+// PasswordSource is where the password is read from, Option is one
+// of the --password-* options and Value its argument. The zero value
+// reads the password from the terminal.
+type PasswordSource struct {
+	Option string
+	Value  string
 }
 
 func NewArgsMetaData() ArgsMetaData {
-    if validateNArgs(MinimumNumberOfArgs) {
-        return ArgsMetaData{
-            FileNames: extractFilenames(),
-            Operation: extractOperation(),
-			NumOfFiles: len(extractFilenames()),
-        }
-    }
-    return ArgsMetaData{}
-}
-
-func (md *ArgsMetaData) IsValid() (bool,error){
-	if md.Operation == "" || md.FileNames == nil {
-		return false, errors.New(NoArgs)
-	} 
-	if !validOperation(md.Operation) {
-		return false, errors.New(esccode.Red+InvalidOpErr+esccode.Reset)
+	if validateNArgs(2) {
+		md := ArgsMetaData{
+			Operation: extractOperation(),
+			MinScore:  DefaultMinScore,
+			Words:     passphrase.DefaultWords,
+			Walk:      walk.DefaultPolicy,
+			Jobs:      runtime.NumCPU(),
+			Passes:    shred.DefaultPasses,
+		}
+		md.extractOptions(extractFilenames())
+		md.applyConfig()
+		esccode.SetMode(md.Color)
+		if md.FilesFrom != "" {
+			md.filesFromErr = md.readFilesFrom()
+		}
+		if md.Operation == VaultOp && len(md.FileNames) != 0 {
+			md.VaultAction = md.FileNames[0]
+			md.VaultArgs = md.FileNames[min(2, len(md.FileNames)):]
+			md.FileNames = md.FileNames[1:min(2, len(md.FileNames))]
+		}
+		md.NumOfFiles = len(md.FileNames)
+		return md
+	}
+	return ArgsMetaData{}
+}
+
+// UsesPassword reports whether the operation needs a password,
+// public and private key files replace it.
+func (md *ArgsMetaData) UsesPassword() bool {
+	switch md.Operation {
+	case KeygenOp, ListSlotsOp, GeneratePassphraseOp, InspectOp, HelpOp, ConfigOp:
+		return false
+	case EncryptionOp, PackOp, VaultOp:
+		if !md.Encrypts() {
+			return md.Identity == ""
+		}
+		return !md.NoPassword && (len(md.Recipients) == 0 || md.Keyfile != "")
+	}
+	return md.Identity == ""
+}
+
+// Encrypts reports whether the operation creates new encrypted files,
+// with a new password and recipients.
+func (md *ArgsMetaData) Encrypts() bool {
+	switch md.Operation {
+	case EncryptionOp, PackOp:
+		return true
+	case VaultOp:
+		return md.VaultAction == VaultCreate
+	}
+	return false
+}
+
+// Batch reports whether the operation goes through its files with
+// the pool of jobs and ends with a summary of the results.
+func (md *ArgsMetaData) Batch() bool {
+	switch md.Operation {
+	case EncryptionOp, DecryptionOp, VerifyOp:
+		return true
+	}
+	return false
+}
+
+func (md *ArgsMetaData) IsValid() (bool, error) {
+	if md.Operation == "" {
+		return false, errors.New(noArgs())
+	}
+	cmd, ok := LookupCommand(md.Operation)
+	if !ok {
+		return false, errors.New(esccode.Red + UnknownCommandErr + md.Operation + esccode.Reset)
+	}
+	if md.Help || md.Operation == HelpOp {
+		return true, nil
+	}
+	if !esccode.ValidMode(md.Color) {
+		return false, errors.New(esccode.Red + ColorErr + esccode.Reset)
+	}
+	if md.configErr != nil {
+		return false, errors.New(esccode.Red + md.configErr.Error() + esccode.Reset)
+	}
+	if md.passwordRefused {
+		return false, errors.New(esccode.Red + PasswordOptErr + esccode.Reset)
+	}
+	if md.FileNames == nil && md.FilesFrom == "" && md.Operation != GeneratePassphraseOp && md.Operation != ConfigOp {
+		return false, errors.New(esccode.Red + NoFilesErr + md.Operation + esccode.Reset)
+	}
+	if len(md.invalidOptions) != 0 {
+		return false, errors.New(esccode.Red + InvalidOptionErr + strings.Join(md.invalidOptions, " ") + esccode.Reset)
+	}
+	for _, option := range md.givenOptions {
+		if !cmd.Accepts(option) {
+			return false, errors.New(esccode.Red + UnsupportedOptionErr + option + esccode.Reset)
+		}
+	}
+	if md.Operation == ConfigOp {
+		if ok, err := md.validConfig(); !ok {
+			return false, errors.New(esccode.Red + err.Error() + esccode.Reset)
+		}
+		return true, nil
+	}
+	if len(md.Recipients) != 0 && !md.Encrypts() {
+		return false, errors.New(esccode.Red + RecipientOptErr + esccode.Reset)
+	}
+	if md.Identity != "" && !md.acceptsIdentity() {
+		return false, errors.New(esccode.Red + IdentityOptErr + esccode.Reset)
+	}
+	if md.passwordSources > 1 {
+		return false, errors.New(esccode.Red + PasswordSourcesErr + esccode.Reset)
+	}
+	if md.Password.Option == PasswordFDOpt {
+		if _, err := strconv.Atoi(md.Password.Value); err != nil {
+			return false, errors.New(esccode.Red + PasswordFDErr + esccode.Reset)
+		}
+	}
+	if md.MinScore < 0 {
+		return false, errors.New(esccode.Red + MinScoreErr + esccode.Reset)
+	}
+	if md.Jobs < 1 {
+		return false, errors.New(esccode.Red + JobsErr + esccode.Reset)
+	}
+	if md.Passes < 1 || md.Passes > shred.MaxPasses {
+		return false, errors.New(esccode.Red + PassesErr + esccode.Reset)
+	}
+	if !md.Shred && contains(md.givenOptions, PassesOpt) {
+		return false, errors.New(esccode.Red + PassesOptErr + esccode.Reset)
+	}
+	if md.DeleteOriginals && md.KeepOriginals {
+		return false, errors.New(esccode.Red + OriginalsOptErr + esccode.Reset)
+	}
+	if md.Shred && md.KeepOriginals && contains(md.givenOptions, ShredOpt) {
+		return false, errors.New(esccode.Red + ShredKeepErr + esccode.Reset)
+	}
+	if md.Words < passphrase.MinWords || md.Words > passphrase.MaxWords {
+		return false, errors.New(esccode.Red + WordsErr + esccode.Reset)
+	}
+	if md.JSON && md.GeneratePassphrase {
+		return false, errors.New(esccode.Red + JSONPromptErr + esccode.Reset)
+	}
+	if md.GeneratePassphrase && (!md.Encrypts() || !md.UsesPassword() || md.Password.Option != "") {
+		return false, errors.New(esccode.Red + GeneratePassphraseOptErr + esccode.Reset)
+	}
+	if md.FilesFrom != "" {
+		if ok, err := md.validFilesFrom(); !ok {
+			return false, err
+		}
+	} else if md.NullSeparated {
+		return false, errors.New(esccode.Red + NullSeparatorOptErr + esccode.Reset)
+	}
+	if (len(md.Walk.Include) != 0 || len(md.Walk.Exclude) != 0) && !md.takesFiles() {
+		return false, errors.New(esccode.Red + FilterOptErr + esccode.Reset)
+	}
+	if err := md.Walk.ValidPatterns(); err != nil {
+		return false, errors.New(esccode.Red + err.Error() + esccode.Reset)
+	}
+	if md.Operation == GeneratePassphraseOp {
+		if md.NumOfFiles != 0 {
+			return false, errors.New(esccode.Red + GeneratePassphraseArgsErr + esccode.Reset)
+		}
+		return true, nil
+	}
+	if md.OutputDir != "" && md.Operation != EncryptionOp && md.Operation != DecryptionOp && md.Operation != UnpackOp && md.VaultAction != VaultExtract {
+		return false, errors.New(esccode.Red + OutputDirOptErr + esccode.Reset)
+	}
+	if md.KeepPaths && md.OutputDir == "" && contains(md.givenOptions, KeepPathsOpt) {
+		return false, errors.New(esccode.Red + KeepPathsOptErr + esccode.Reset)
+	}
+	if policy, ok := validWalkPolicy(md.Walk); !ok {
+		return false, errors.New(esccode.Red + WalkPolicyErr + policy + esccode.Reset)
+	}
+	if md.NoPassword && (!md.Encrypts() || (md.Keyfile == "" && len(md.Recipients) == 0)) {
+		return false, errors.New(esccode.Red + NoPasswordOptErr + esccode.Reset)
+	}
+	if md.Operation == KeygenOp {
+		if md.NumOfFiles != 1 {
+			return false, errors.New(esccode.Red + KeygenArgsErr + esccode.Reset)
+		}
+		return true, nil
+	}
+	if md.Operation == PackOp {
+		return md.validPack()
+	}
+	if md.Operation == VaultOp {
+		return md.validVault()
 	}
 	if !validFilenames(md.FileNames) {
-		return false, errors.New(esccode.Red+InvalidFilenamesErr+esccode.Reset)
+		return false, errors.New(esccode.Red + InvalidFilenamesErr + esccode.Reset)
+	}
+	if err := md.expandDirectories(); err != nil {
+		return false, errors.New(esccode.Red + err.Error() + esccode.Reset)
+	}
+	if err := md.filterFiles(); err != nil {
+		return false, errors.New(esccode.Red + err.Error() + esccode.Reset)
 	}
 
-	if ok,op := validExtension(md.FileNames,md.Operation); !ok {
+	if ok, op := validExtension(md.FileNames, md.Operation); !ok {
 		if op == EncryptionOp {
-			return false, errors.New(esccode.Red+InvalidEnExtErr+esccode.Reset)
-		}else {
-			return false, errors.New(esccode.Red+InvalidDeExtErr+esccode.Reset)
+			return false, errors.New(esccode.Red + InvalidEnExtErr + esccode.Reset)
+		} else {
+			return false, errors.New(esccode.Red + InvalidDeExtErr + esccode.Reset)
 		}
 	}
+	if err := md.validOutputs(); err != nil {
+		return false, errors.New(esccode.Red + err.Error() + esccode.Reset)
+	}
 
 	return true, nil
 }
 
-func validOperation(operation string) bool {
-	return operation == EncryptionOp || operation == DecryptionOp
+// HelpText is the --help text asked for, a command's or the list of
+// commands.
+func (md *ArgsMetaData) HelpText() string {
+	name := md.Operation
+	if md.Operation == HelpOp {
+		if len(md.FileNames) == 0 {
+			return CommandsHelp()
+		}
+		name = md.FileNames[0]
+	}
+	cmd, ok := LookupCommand(name)
+	if !ok {
+		return esccode.Red + UnknownCommandErr + name + esccode.Reset + "\n\n" + CommandsHelp()
+	}
+	return cmd.Help()
+}
+
+// validPack checks the archive, the first filename, which must be a
+// new .enc file. The files and directories after it are walked when
+// the archive is written.
+func (md *ArgsMetaData) validPack() (bool, error) {
+	if md.NumOfFiles < 2 {
+		return false, errors.New(esccode.Red + PackArgsErr + esccode.Reset)
+	}
+	archive := md.FileNames[0]
+	if !strings.HasSuffix(archive, EncryptedFileExt) {
+		return false, errors.New(esccode.Red + InvalidDeExtErr + esccode.Reset)
+	}
+	if _, err := os.Lstat(archive); err == nil {
+		return false, errors.New(esccode.Red + ArchiveExistsErr + archive + esccode.Reset)
+	}
+	if !validFilenames(md.FileNames[1:]) {
+		return false, errors.New(esccode.Red + InvalidFilenamesErr + esccode.Reset)
+	}
+	return true, nil
+}
+
+// takesFiles reports whether the operation works on a list of
+// files, which a file list and the filters can make up.
+func (md *ArgsMetaData) takesFiles() bool {
+	switch md.Operation {
+	case EncryptionOp, DecryptionOp, VerifyOp, InspectOp, RekeyOp, AddSlotOp, RemoveSlotOp, ListSlotsOp, PackOp, MigrateOp:
+		return true
+	case VaultOp:
+		return md.VaultAction == VaultAdd
+	}
+	return false
+}
+
+// prompts reports whether the operation asks anything on the
+// terminal, which it can't do once stdin carries the file list.
+func (md *ArgsMetaData) prompts() bool {
+	switch md.Operation {
+	case RekeyOp, AddSlotOp, RemoveSlotOp:
+		return true
+	}
+	if md.GeneratePassphrase {
+		return true
+	}
+	return md.UsesPassword() && (md.Password.Option == "" || md.Password.Option == PasswordStdinOpt)
+}
+
+func (md *ArgsMetaData) validFilesFrom() (bool, error) {
+	if !md.takesFiles() {
+		return false, errors.New(esccode.Red + FilesFromOptErr + esccode.Reset)
+	}
+	if md.FilesFrom == StdinName && md.prompts() {
+		return false, errors.New(esccode.Red + FilesFromStdinErr + esccode.Reset)
+	}
+	if md.filesFromErr != nil {
+		return false, errors.New(esccode.Red + md.filesFromErr.Error() + esccode.Reset)
+	}
+	return true, nil
+}
+
+// readFilesFrom appends the filenames listed in FilesFrom, empty
+// entries are skipped.
+func (md *ArgsMetaData) readFilesFrom() error {
+	var content []byte
+	var err error
+	if md.FilesFrom == StdinName {
+		content, err = io.ReadAll(os.Stdin)
+	} else {
+		content, err = os.ReadFile(md.FilesFrom)
+	}
+	if err != nil {
+		return err
+	}
+
+	separator := "\n"
+	if md.NullSeparated {
+		separator = "\x00"
+	}
+	for _, name := range strings.Split(string(content), separator) {
+		if !md.NullSeparated {
+			name = strings.TrimSuffix(name, "\r")
+		}
+		if name != "" {
+			md.FileNames = append(md.FileNames, name)
+		}
+	}
+	return nil
+}
+
+// filterFiles applies the include and exclude patterns to the files
+// of the key slot operations, which aren't walked.
+func (md *ArgsMetaData) filterFiles() error {
+	if md.Operation == EncryptionOp || md.Operation == DecryptionOp || md.Operation == VerifyOp {
+		return nil
+	}
+	var kept []string
+	for _, filename := range md.FileNames {
+		if md.Walk.Wanted(filename) {
+			kept = append(kept, filename)
+		}
+	}
+	if len(kept) == 0 {
+		return errors.New(NoFilesFoundErr)
+	}
+	md.FileNames = kept
+	md.NumOfFiles = len(kept)
+	return nil
+}
+
+// acceptsIdentity reports whether a private key can replace the
+// password, to read files or to authorize a key slot change.
+func (md *ArgsMetaData) acceptsIdentity() bool {
+	switch md.Operation {
+	case DecryptionOp, VerifyOp, UnpackOp, AddSlotOp, RemoveSlotOp:
+		return true
+	case VaultOp:
+		return !md.Encrypts()
+	}
+	return false
+}
+
+func (md *ArgsMetaData) validVault() (bool, error) {
+	var minArgs, maxArgs int
+	switch md.VaultAction {
+	case VaultCreate, VaultList, VaultCompact:
+	case VaultAdd, VaultRemove:
+		minArgs, maxArgs = 1, -1
+	case VaultExtract:
+		maxArgs = -1
+	default:
+		return false, errors.New(esccode.Red + VaultActionErr + esccode.Reset)
+	}
+	if md.NumOfFiles == 0 {
+		return false, errors.New(esccode.Red + VaultActionErr + esccode.Reset)
+	}
+	if len(md.VaultArgs) < minArgs || (maxArgs >= 0 && len(md.VaultArgs) > maxArgs) {
+		return false, errors.New(esccode.Red + VaultArgsErr + md.VaultAction + esccode.Reset)
+	}
+
+	vault := md.FileNames[0]
+	if !strings.HasSuffix(vault, EncryptedFileExt) {
+		return false, errors.New(esccode.Red + InvalidDeExtErr + esccode.Reset)
+	}
+	_, err := os.Lstat(vault)
+	if md.VaultAction == VaultCreate && err == nil {
+		return false, errors.New(esccode.Red + ArchiveExistsErr + vault + esccode.Reset)
+	}
+	if md.VaultAction != VaultCreate && err != nil {
+		return false, errors.New(esccode.Red + InvalidFilenamesErr + esccode.Reset)
+	}
+	if md.VaultAction == VaultAdd && !validFilenames(md.VaultArgs) {
+		return false, errors.New(esccode.Red + InvalidFilenamesErr + esccode.Reset)
+	}
+	return true, nil
+}
+
+func validWalkPolicy(p walk.Policy) (string, bool) {
+	switch p.Symlinks {
+	case walk.SymlinkSkip, walk.SymlinkFollow, walk.SymlinkError:
+	default:
+		return SymlinksOpt + " " + p.Symlinks, false
+	}
+	switch p.Special {
+	case walk.SpecialSkip, walk.SpecialError:
+	default:
+		return SpecialOpt + " " + p.Special, false
+	}
+	switch p.Hidden {
+	case walk.HiddenInclude, walk.HiddenSkip:
+	default:
+		return HiddenOpt + " " + p.Hidden, false
+	}
+	return "", true
+}
+
+// expandDirectories replaces the directories given with -r by the
+// files inside them, regular files to encrypt or .enc files to
+// decrypt or verify. With an output directory every output goes
+// there, flat or under its relative path with KeepPaths.
+func (md *ArgsMetaData) expandDirectories() error {
+	if md.Operation != EncryptionOp && md.Operation != DecryptionOp && md.Operation != VerifyOp {
+		return nil
+	}
+	if !md.Recursive {
+		for _, filename := range md.FileNames {
+			if info, err := os.Stat(filename); err == nil && info.IsDir() {
+				return errors.New(DirectoryErr + filename)
+			}
+		}
+	}
+
+	policy := md.Walk
+	policy.SkipDir = md.OutputDir
+	entries, err := walk.Files(md.FileNames, policy, func(name string) bool {
+		return (md.Operation == EncryptionOp) != strings.HasSuffix(name, EncryptedFileExt)
+	})
+	if err != nil {
+		return err
+	}
+	if len(entries) == 0 {
+		return errors.New(NoFilesFoundErr)
+	}
+
+	// a file given twice is done once
+	seen := make(map[string]bool, len(entries))
+	md.FileNames = make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if seen[entry.Path] {
+			continue
+		}
+		seen[entry.Path] = true
+		md.FileNames = append(md.FileNames, entry.Path)
+		if md.OutputDir != "" {
+			if md.Outputs == nil {
+				md.Outputs = make(map[string]string)
+			}
+			md.Outputs[entry.Path] = OutputName(filepath.Join(md.OutputDir, md.outputPath(entry)), md.Operation)
+		}
+	}
+	md.NumOfFiles = len(md.FileNames)
+	return nil
+}
+
+// outputPath is where entry goes under the output directory, its
+// base name, or with KeepPaths its path from the current directory
+// when it is below it and else its path from the directory walked.
+func (md *ArgsMetaData) outputPath(entry walk.Entry) string {
+	if !md.KeepPaths {
+		return filepath.Base(entry.Path)
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		return entry.Rel
+	}
+	abs, err := filepath.Abs(entry.Path)
+	if err != nil {
+		return entry.Rel
+	}
+	if path, err := filepath.Rel(wd, abs); err == nil && filepath.IsLocal(path) {
+		return path
+	}
+	return entry.Rel
+}
+
+// validOutputs refuses a batch where two inputs, such as two files
+// with the same base name going to one output directory, would be
+// written to the same output.
+func (md *ArgsMetaData) validOutputs() error {
+	if md.Operation != EncryptionOp && md.Operation != DecryptionOp {
+		return nil
+	}
+	inputs := make(map[string]string, len(md.FileNames))
+	for _, filename := range md.FileNames {
+		output := filepath.Clean(md.Output(filename))
+		if other, ok := inputs[output]; ok {
+			return errors.New(OutputCollisionErr + other + " and " + filename + " -> " + output)
+		}
+		inputs[output] = filename
+	}
+	return nil
+}
+
+// Output is the file written for filename.
+func (md *ArgsMetaData) Output(filename string) string {
+	if output, ok := md.Outputs[filename]; ok {
+		return output
+	}
+	return OutputName(filename, md.Operation)
+}
+
+// OutputName is the output written next to filename, with the .enc
+// extension added for encryption and removed for decryption.
+func OutputName(filename, op string) string {
+	if op == EncryptionOp {
+		return filename + EncryptedFileExt
+	}
+	return strings.TrimSuffix(filename, EncryptedFileExt)
 }
 
 func validFilenames(filenames []string) bool {
 	for _, v := range filenames {
 		_, err := os.Stat(v)
-		
+
 		if errors.Is(err, os.ErrNotExist) {
 			return false
 		}
@@ -78,36 +749,202 @@ func validFilenames(filenames []string) bool {
 	return true
 }
 
-func validExtension(filenames []string, op string) (bool,string) {
+func validExtension(filenames []string, op string) (bool, string) {
 	for _, v := range filenames {
-		extractedExt := extractExt(v) 
-		if extractedExt != EncryptedFileExt && op == DecryptionOp {
+		encrypted := strings.HasSuffix(v, EncryptedFileExt)
+		if !encrypted && op != EncryptionOp {
 			return false, op
-		}else if extractedExt == EncryptedFileExt && op == EncryptionOp{
+		} else if encrypted && op == EncryptionOp {
 			return false, op
 		}
 	}
 	return true, ""
 }
 
-func extractExt(filename string) string {
-	return filename[len(filename)-len(EncryptedFileExt):]
+// extractOptions splits the arguments after the operation into
+// filenames and options. An option takes its value either as
+// "--name value" or "--name=value", everything after "--" is a filename.
+func (md *ArgsMetaData) extractOptions(args []string) {
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" {
+			md.FileNames = append(md.FileNames, args[i+1:]...)
+			return
+		}
+		switch arg {
+		case RecursiveOpt:
+			arg = RecursiveLongOpt
+		case JobsOpt:
+			arg = JobsLongOpt
+		case OutputOpt:
+			arg = OutputDirOpt
+		case "-h":
+			arg = HelpOpt
+		}
+		if !strings.HasPrefix(arg, "-") || arg == StdinName {
+			md.FileNames = append(md.FileNames, arg)
+			continue
+		}
+		name, _, _ := strings.Cut(arg, "=")
+		if name == OutputRootOpt {
+			md.KeepPaths = true
+			arg, name = OutputDirOpt+strings.TrimPrefix(arg, OutputRootOpt), OutputDirOpt
+		}
+		if !knownOption(name) {
+			md.invalidOptions = append(md.invalidOptions, name)
+			continue
+		}
+		if name != HelpOpt {
+			md.givenOptions = append(md.givenOptions, name)
+		}
+		if md.setFlag(arg) {
+			continue
+		}
+
+		name, value, ok := strings.Cut(arg, "=")
+		if !ok && i+1 < len(args) {
+			i++
+			value, ok = args[i], true
+		}
+		if name == PasswordOpt {
+			// refused whether a value follows or not
+			md.passwordRefused = true
+			continue
+		}
+		if !ok || value == "" {
+			md.invalidOptions = append(md.invalidOptions, name)
+			continue
+		}
+		md.setOption(name, value)
+	}
+}
+
+// setFlag sets an option which takes no value, it reports false for
+// the others.
+func (md *ArgsMetaData) setFlag(name string) bool {
+	switch name {
+	case HelpOpt:
+		md.Help = true
+	case NullSeparatorOpt:
+		md.NullSeparated = true
+	case NoPasswordOpt:
+		md.NoPassword = true
+	case RecursiveLongOpt:
+		md.Recursive = true
+	case KeepPathsOpt:
+		md.KeepPaths = true
+	case ForceOpt:
+		md.Force = true
+	case JSONOpt:
+		md.JSON = true
+	case ShredOpt:
+		md.Shred = true
+	case DeleteOriginalsOpt:
+		md.DeleteOriginals = true
+	case KeepOriginalsOpt:
+		md.KeepOriginals = true
+	case DeleteEncryptedOpt:
+		md.DeleteEncrypted = true
+	case GeneratePassphraseOpt:
+		md.GeneratePassphrase = true
+	case PasswordStdinOpt:
+		md.setPasswordSource(name, "")
+	default:
+		return false
+	}
+	return true
+}
+
+// setOption sets an option which takes a value, a malformed number
+// is kept as -1 for IsValid to refuse.
+func (md *ArgsMetaData) setOption(name, value string) {
+	switch name {
+	case RecipientOpt:
+		md.Recipients = append(md.Recipients, value)
+	case IdentityOpt:
+		md.Identity = value
+	case KeyfileOpt:
+		md.Keyfile = value
+	case SymlinksOpt:
+		md.Walk.Symlinks = value
+	case SpecialOpt:
+		md.Walk.Special = value
+	case HiddenOpt:
+		md.Walk.Hidden = value
+	case OutputDirOpt:
+		md.OutputDir = value
+	case ColorOpt:
+		md.Color = value
+	case FilesFromOpt:
+		md.FilesFrom = value
+	case IncludeOpt:
+		md.Walk.Include = append(md.Walk.Include, value)
+	case ExcludeOpt:
+		md.Walk.Exclude = append(md.Walk.Exclude, value)
+	case PasswordFileOpt, PasswordFDOpt, PasswordEnvOpt, PasswordCommandOpt:
+		md.setPasswordSource(name, value)
+	case JobsLongOpt:
+		jobs, err := strconv.Atoi(value)
+		if err != nil {
+			jobs = -1
+		}
+		md.Jobs = jobs
+	case PassesOpt:
+		passes, err := strconv.Atoi(value)
+		if err != nil {
+			passes = -1
+		}
+		md.Passes = passes
+	case WordsOpt:
+		words, err := strconv.Atoi(value)
+		if err != nil {
+			words = -1
+		}
+		md.Words = words
+	case MinScoreOpt:
+		score, err := strconv.Atoi(value)
+		if err != nil || score < 0 || score > MaxScore {
+			score = -1
+		}
+		md.MinScore = score
+	default:
+		md.invalidOptions = append(md.invalidOptions, name)
+	}
+}
+
+func (md *ArgsMetaData) setPasswordSource(option, value string) {
+	md.Password = PasswordSource{Option: option, Value: value}
+	md.passwordSources++
 }
 
 func extractFilenames() []string {
-    if validateNArgs(MinimumNumberOfArgs) {
-        return os.Args[2:]
-    }
-    return nil
+	if validateNArgs(MinimumNumberOfArgs) {
+		return os.Args[2:]
+	}
+	return nil
 }
 
+// extractOperation returns the command, an alias is replaced by
+// its name.
 func extractOperation() string {
-    if validateNArgs(MinimumNumberOfArgs) {
-        return os.Args[1]
-    }
-    return ""
+	if validateNArgs(2) {
+		if cmd, ok := LookupCommand(os.Args[1]); ok {
+			return cmd.Name
+		}
+		return os.Args[1]
+	}
+	return ""
+}
+
+// knownOption reports whether name is an option of any command.
+func knownOption(name string) bool {
+	if name == HelpOpt {
+		return true
+	}
+	_, ok := optionHelp[name]
+	return ok
 }
 
 func validateNArgs(n int) bool {
-    return len(os.Args) >= n
+	return len(os.Args) >= n
 }
@@ -0,0 +1,227 @@
+package cmdlineargs
+
+import (
+	"fmt"
+	"strings"
+
+	esccode "github.com/ShuaibKhan786/cipher-project/internal/escapecode"
+)
+
+// Command describes one operation for validation and --help, Aliases
+// are the older spellings still accepted.
+type Command struct {
+	Name    string
+	Aliases []string
+	Usage   string
+	Summary string
+	Options []string
+}
+
+var (
+	passwordSources = []string{PasswordFileOpt, PasswordFDOpt, PasswordEnvOpt, PasswordCommandOpt, PasswordStdinOpt, PasswordOpt}
+	passwordOptions = options(passwordSources, []string{KeyfileOpt})
+	newKeyOptions   = []string{MinScoreOpt, GeneratePassphraseOpt, WordsOpt, RecipientOpt, NoPasswordOpt}
+	walkOptions     = []string{SymlinksOpt, SpecialOpt, HiddenOpt, IncludeOpt, ExcludeOpt}
+	listOptions     = []string{FilesFromOpt, NullSeparatorOpt, IncludeOpt, ExcludeOpt}
+	batchOptions    = []string{RecursiveLongOpt, SymlinksOpt, SpecialOpt, HiddenOpt, JobsLongOpt, JSONOpt}
+	outputOptions   = []string{OutputDirOpt, KeepPathsOpt, ForceOpt}
+	removeOptions   = []string{DeleteOriginalsOpt, KeepOriginalsOpt, ShredOpt, PassesOpt}
+	// globalOptions are taken by every command
+	globalOptions = []string{ColorOpt, HelpOpt}
+)
+
+var Commands = []Command{
+	{
+		Name: EncryptionOp, Aliases: []string{"-e"},
+		Usage:   "encrypt [options] files...",
+		Summary: "Encrypts files, each one to file.enc next to it.",
+		Options: options(passwordOptions, newKeyOptions, listOptions, batchOptions, outputOptions, removeOptions),
+	},
+	{
+		Name: DecryptionOp, Aliases: []string{"-d"},
+		Usage:   "decrypt [options] files.enc...",
+		Summary: "Decrypts .enc files, each one to the file without .enc next to it.",
+		Options: options(passwordOptions, []string{IdentityOpt}, listOptions, batchOptions, outputOptions, []string{DeleteEncryptedOpt}),
+	},
+	{
+		Name:    VerifyOp,
+		Usage:   "verify [options] files.enc...",
+		Summary: "Checks that .enc files open and that every chunk is intact, nothing is written.",
+		Options: options(passwordOptions, []string{IdentityOpt}, listOptions, batchOptions),
+	},
+	{
+		Name:    InspectOp,
+		Usage:   "inspect [options] files.enc...",
+		Summary: "Shows the header of .enc files, format version, kind, size and key slots. No password is needed.",
+		Options: listOptions,
+	},
+	{
+		Name: RekeyOp, Aliases: []string{"-k"},
+		Usage:   "rekey [options] files.enc...",
+		Summary: "Changes the password of .enc files, only their header is rewritten.",
+		Options: options(passwordOptions, []string{MinScoreOpt}, listOptions),
+	},
+	{
+		Name:    MigrateOp,
+		Usage:   "migrate [options] files.enc...",
+		Summary: "Rewrites .enc files made before the file header in the current format, with the same password.",
+		Options: options(passwordSources, listOptions),
+	},
+	{
+		Name:    AddSlotOp,
+		Usage:   "add-slot [options] files.enc...",
+		Summary: "Adds a password to .enc files, the current one keeps working.",
+		Options: options(passwordOptions, []string{IdentityOpt, MinScoreOpt}, listOptions),
+	},
+	{
+		Name:    RemoveSlotOp,
+		Usage:   "remove-slot [options] files.enc...",
+		Summary: "Removes a key slot from .enc files, the last one is kept.",
+		Options: options(passwordOptions, []string{IdentityOpt}, listOptions),
+	},
+	{
+		Name:    ListSlotsOp,
+		Usage:   "list-slots [options] files.enc...",
+		Summary: "Lists the key slots of .enc files.",
+		Options: listOptions,
+	},
+	{
+		Name:    KeygenOp,
+		Usage:   "keygen keyname",
+		Summary: "Creates an X25519 key pair, keyname for the private key and keyname.pub for the public key.",
+	},
+	{
+		Name:    GeneratePassphraseOp,
+		Usage:   "generate-passphrase [options]",
+		Summary: "Prints a random diceware passphrase.",
+		Options: []string{WordsOpt},
+	},
+	{
+		Name:    PackOp,
+		Usage:   "pack [options] archive.enc files-and-directories...",
+		Summary: "Packs files and directories into one encrypted tar archive.",
+		Options: options(passwordOptions, newKeyOptions, walkOptions, []string{FilesFromOpt, NullSeparatorOpt}),
+	},
+	{
+		Name:    UnpackOp,
+		Usage:   "unpack [options] archive.enc",
+		Summary: "Restores an archive under the output root, or the current directory.",
+		Options: options(passwordOptions, []string{IdentityOpt, OutputDirOpt}),
+	},
+	{
+		Name:    VaultOp,
+		Usage:   "vault create|add|list|extract|remove|compact [options] vault.enc [names...]",
+		Summary: "Keeps files in one encrypted store with an encrypted index.",
+		Options: options(passwordOptions, newKeyOptions, []string{IdentityOpt, OutputDirOpt}, walkOptions, []string{FilesFromOpt, NullSeparatorOpt}),
+	},
+	{
+		Name:    ConfigOp,
+		Usage:   "config show [command]",
+		Summary: "Shows the effective defaults, of one command or all of them, and the config file or default each one comes from.",
+		Options: configurable,
+	},
+	{
+		Name: HelpOp, Aliases: []string{HelpOpt, "-h"},
+		Usage:   "help [command]",
+		Summary: "Shows the commands, or the options of one command.",
+	},
+}
+
+var optionHelp = map[string]string{
+	PasswordFileOpt:       "path\tread the password from the first line of a file",
+	PasswordFDOpt:         "n\tread the password from a file descriptor",
+	PasswordEnvOpt:        "name\tread the password from an environment variable",
+	PasswordCommandOpt:    "cmd\tread the password from the output of a command",
+	PasswordStdinOpt:      "\tread the password from stdin",
+	PasswordOpt:           "\trefused, a password on the command line is visible to other users",
+	KeyfileOpt:            "path\tcombine a keyfile with the password",
+	MinScoreOpt:           "0-4\tminimum strength of a new password (default 2)",
+	GeneratePassphraseOpt: "\toffer a generated passphrase instead of typing one",
+	WordsOpt:              "n\twords of a generated passphrase, 4 to 20 (default 7)",
+	RecipientOpt:          "key.pub\tencrypt for an X25519 public key, repeatable",
+	NoPasswordOpt:         "\tuse only the keyfile or the recipients",
+	IdentityOpt:           "key\topen with an X25519 private key instead of a password",
+	FilesFromOpt:          "path\tread more filenames from a file, - for stdin",
+	NullSeparatorOpt:      "\tthe file list is NUL separated",
+	IncludeOpt:            "glob\ttake only matching files, repeatable",
+	ExcludeOpt:            "glob\tleave out matching files and directories, repeatable",
+	RecursiveLongOpt:      "\twalk directories, also -r",
+	SymlinksOpt:           "skip|follow|error\tsymbolic links found in directories (default skip)",
+	SpecialOpt:            "skip|error\tdevices, pipes and sockets (default skip)",
+	HiddenOpt:             "include|skip\tnames starting with a dot (default include)",
+	JobsLongOpt:           "n\tfiles processed at once, also -j (default the number of CPUs)",
+	ColorOpt:              "auto|always|never\tcolors, auto colors a terminal unless NO_COLOR is set",
+	HelpOpt:               "\tshow this help, also -h",
+	JSONOpt:               "\tnewline-delimited JSON events on stdout instead of text, nothing is asked",
+	OutputDirOpt:          "dir\twrite the outputs to dir, also -o",
+	KeepPathsOpt:          "\tkeep the relative directories of the inputs under the output directory",
+	ForceOpt:              "\toverwrite outputs which already exist",
+	DeleteOriginalsOpt:    "\tremove the originals once encrypted and read back, without asking",
+	KeepOriginalsOpt:      "\tkeep the originals, without asking",
+	DeleteEncryptedOpt:    "\tremove the .enc files once decrypted",
+	ShredOpt:              "\toverwrite the originals with random data before removing them",
+	PassesOpt:             "n\toverwrite passes of " + ShredOpt + ", 1 to 35 (default 3)",
+}
+
+func options(lists ...[]string) []string {
+	var all []string
+	for _, list := range lists {
+		for _, option := range list {
+			if !contains(all, option) {
+				all = append(all, option)
+			}
+		}
+	}
+	return all
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// LookupCommand returns the command named, or aliased, name.
+func LookupCommand(name string) (Command, bool) {
+	for _, cmd := range Commands {
+		if cmd.Name == name || contains(cmd.Aliases, name) {
+			return cmd, true
+		}
+	}
+	return Command{}, false
+}
+
+// Accepts reports whether option can be given to the command.
+func (cmd Command) Accepts(option string) bool {
+	return contains(cmd.Options, option) || contains(globalOptions, option)
+}
+
+// Help is the --help text of the command.
+func (cmd Command) Help() string {
+	var b strings.Builder
+	b.WriteString(esccode.Green + "Usage: EncryptEase " + cmd.Usage + esccode.Reset + "\n\n")
+	b.WriteString(cmd.Summary + "\n")
+	if len(cmd.Aliases) != 0 {
+		b.WriteString("Also: " + strings.Join(cmd.Aliases, ", ") + "\n")
+	}
+	b.WriteString("\nOptions:\n")
+	for _, option := range options(cmd.Options, globalOptions) {
+		arg, text, _ := strings.Cut(optionHelp[option], "\t")
+		fmt.Fprintf(&b, "  %s%-32s%s%s\n", esccode.Yellow, strings.TrimSpace(option+" "+arg), esccode.Reset, text)
+	}
+	return b.String()
+}
+
+// CommandsHelp lists every command with its summary.
+func CommandsHelp() string {
+	var b strings.Builder
+	b.WriteString(esccode.Green + "Usage: EncryptEase command [options] [files]" + esccode.Reset + "\n\nCommands:\n")
+	for _, cmd := range Commands {
+		fmt.Fprintf(&b, "  %s%-20s%s%s\n", esccode.Yellow, cmd.Name, esccode.Reset, cmd.Summary)
+	}
+	b.WriteString("\nRun EncryptEase command --help for the options of a command.\n")
+	return b.String()
+}
@@ -0,0 +1,215 @@
+package cmdlineargs
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	config "github.com/ShuaibKhan786/cipher-project/internal/config"
+	esccode "github.com/ShuaibKhan786/cipher-project/internal/escapecode"
+)
+
+const (
+	ConfigOp   = "config"
+	ConfigShow = "show"
+
+	ConfigActionErr = "config takes show, optionally followed by a command"
+	ConfigKeyErr    = "unknown setting: "
+	ConfigBoolErr   = "must be true or false: "
+	ConfigUserErr   = "can only be set in the user config file: "
+	DefaultSource   = "default"
+	CommandSource   = "command line"
+)
+
+// Password sources and recipients can't be given a default: the
+// project file is read from whatever directory EncryptEase runs in,
+// and must not be able to run a command or add a key able to
+// decrypt. For the same reason userConfigurable are only read from
+// the user file, they would let a project file weaken the password
+// check, send the outputs elsewhere or change which files are taken.
+var (
+	projectConfigurable = []string{
+		JobsLongOpt, KeepPathsOpt, RecursiveLongOpt, WordsOpt, SpecialOpt, HiddenOpt, ColorOpt, PassesOpt,
+	}
+	userConfigurable = []string{
+		MinScoreOpt, OutputDirOpt, IncludeOpt, ExcludeOpt, SymlinksOpt, ShredOpt, DeleteOriginalsOpt, KeepOriginalsOpt,
+	}
+	// configurable are the options a config file can give a default to
+	configurable = options(projectConfigurable, userConfigurable)
+	// configFlags are set to true or false in a config file
+	configFlags = []string{KeepPathsOpt, RecursiveLongOpt, ShredOpt, DeleteOriginalsOpt, KeepOriginalsOpt}
+	// overriddenBy are the options given on the command line which
+	// drop the config value of an option they can't be used with
+	overriddenBy = map[string][]string{
+		DeleteOriginalsOpt: {KeepOriginalsOpt},
+		KeepOriginalsOpt:   {DeleteOriginalsOpt, ShredOpt},
+	}
+)
+
+// Setting is the effective value of an option and where it came
+// from, DefaultSource, CommandSource or the file:line of a config.
+type Setting struct {
+	Option string
+	Value  string
+	Source string
+}
+
+// applyConfig sets the options of the user and project config files
+// which the command takes and which were not given on the command
+// line. A repeatable option given on the command line replaces its
+// values from the config files.
+func (md *ArgsMetaData) applyConfig() {
+	cfg, err := config.Load()
+	if err != nil {
+		md.configErr = err
+		return
+	}
+	md.ConfigFiles = cfg.Files
+
+	command := md.Operation
+	if md.Operation == ConfigOp {
+		command = md.configCommand()
+	}
+	cmd, known := LookupCommand(command)
+	for _, s := range cfg.For(command) {
+		name := "--" + s.Key
+		if !contains(configurable, name) {
+			md.configErr = errors.New(s.Source + ": " + ConfigKeyErr + s.Key)
+			return
+		}
+		if s.Project && contains(userConfigurable, name) {
+			md.configErr = errors.New(s.Source + ": " + ConfigUserErr + s.Key)
+			return
+		}
+		if contains(md.givenOptions, name) || (known && !cmd.Accepts(name)) || md.overridden(name) {
+			continue
+		}
+		if contains(configFlags, name) {
+			on, err := strconv.ParseBool(s.Value)
+			if err != nil {
+				md.configErr = errors.New(s.Source + ": " + s.Key + " " + ConfigBoolErr + s.Value)
+				return
+			}
+			if !on {
+				continue
+			}
+			md.setFlag(name)
+		} else {
+			md.setOption(name, s.Value)
+		}
+		md.recordSource(name, s.Source)
+	}
+}
+
+// overridden reports whether an option given on the command line
+// can't be used with name, whose config value then gives way.
+func (md *ArgsMetaData) overridden(name string) bool {
+	for _, option := range overriddenBy[name] {
+		if contains(md.givenOptions, option) {
+			return true
+		}
+	}
+	return false
+}
+
+func (md *ArgsMetaData) recordSource(name, source string) {
+	if md.sources == nil {
+		md.sources = make(map[string]string)
+	}
+	if previous, ok := md.sources[name]; ok && (name == IncludeOpt || name == ExcludeOpt) {
+		source = previous + ", " + source
+	}
+	md.sources[name] = source
+}
+
+// configCommand is the command config show was asked about, empty
+// for the settings of every command.
+func (md *ArgsMetaData) configCommand() string {
+	if len(md.FileNames) < 2 {
+		return ""
+	}
+	if cmd, ok := LookupCommand(md.FileNames[1]); ok {
+		return cmd.Name
+	}
+	return md.FileNames[1]
+}
+
+func (md *ArgsMetaData) validConfig() (bool, error) {
+	if md.NumOfFiles == 0 || md.NumOfFiles > 2 || md.FileNames[0] != ConfigShow {
+		return false, errors.New(ConfigActionErr)
+	}
+	if command := md.configCommand(); command != "" {
+		if _, ok := LookupCommand(command); !ok {
+			return false, errors.New(UnknownCommandErr + command)
+		}
+	}
+	return true, nil
+}
+
+// Settings are the configurable options of the command config show
+// was asked about, with their effective values.
+func (md *ArgsMetaData) Settings() []Setting {
+	options := configurable
+	if cmd, ok := LookupCommand(md.configCommand()); ok {
+		options = nil
+		for _, option := range configurable {
+			if cmd.Accepts(option) {
+				options = append(options, option)
+			}
+		}
+	}
+
+	settings := make([]Setting, 0, len(options))
+	for _, option := range options {
+		source, ok := md.sources[option]
+		if !ok {
+			source = DefaultSource
+			if contains(md.givenOptions, option) {
+				source = CommandSource
+			}
+		}
+		settings = append(settings, Setting{Option: option, Value: md.optionValue(option), Source: source})
+	}
+	return settings
+}
+
+func (md *ArgsMetaData) optionValue(name string) string {
+	switch name {
+	case JobsLongOpt:
+		return strconv.Itoa(md.Jobs)
+	case OutputDirOpt:
+		return md.OutputDir
+	case KeepPathsOpt:
+		return strconv.FormatBool(md.KeepPaths)
+	case RecursiveLongOpt:
+		return strconv.FormatBool(md.Recursive)
+	case MinScoreOpt:
+		return strconv.Itoa(md.MinScore)
+	case WordsOpt:
+		return strconv.Itoa(md.Words)
+	case SymlinksOpt:
+		return md.Walk.Symlinks
+	case SpecialOpt:
+		return md.Walk.Special
+	case HiddenOpt:
+		return md.Walk.Hidden
+	case IncludeOpt:
+		return strings.Join(md.Walk.Include, " ")
+	case ExcludeOpt:
+		return strings.Join(md.Walk.Exclude, " ")
+	case ShredOpt:
+		return strconv.FormatBool(md.Shred)
+	case DeleteOriginalsOpt:
+		return strconv.FormatBool(md.DeleteOriginals)
+	case KeepOriginalsOpt:
+		return strconv.FormatBool(md.KeepOriginals)
+	case PassesOpt:
+		return strconv.Itoa(md.Passes)
+	case ColorOpt:
+		if md.Color == "" {
+			return esccode.ColorAuto
+		}
+		return md.Color
+	}
+	return ""
+}
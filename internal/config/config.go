@@ -0,0 +1,134 @@
+package config
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// The user file holds the defaults of every run, the project file in
+// the current directory those of one tree, and overrides them.
+const (
+	DirName     = "encryptease"
+	FileName    = "config"
+	ProjectFile = ".encryptease"
+
+	SyntaxErr  = "expected key = value or [command]"
+	SectionErr = "empty section name"
+)
+
+// Setting is one key = value line. Section is the [command] it was
+// found under, empty for the lines before any section, and Source is
+// where it was read, as file:line. Project is set for the lines of
+// the project file.
+type Setting struct {
+	Key     string
+	Value   string
+	Section string
+	Source  string
+	Project bool
+}
+
+// Config holds the settings of every file read, the user file first.
+type Config struct {
+	Files    []string
+	Settings []Setting
+}
+
+// UserFile is $XDG_CONFIG_HOME/encryptease/config, or the same under
+// the platform config directory.
+func UserFile() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, DirName, FileName), nil
+}
+
+// Load reads the user file and the project file, a missing file is
+// the same as an empty one.
+func Load() (*Config, error) {
+	c := &Config{}
+	paths := []string{ProjectFile}
+	if user, err := UserFile(); err == nil {
+		paths = []string{user, ProjectFile}
+	}
+	for _, path := range paths {
+		settings, err := ReadFile(path)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		c.Files = append(c.Files, path)
+		for i := range settings {
+			settings[i].Project = path == ProjectFile
+		}
+		c.Settings = append(c.Settings, settings...)
+	}
+	return c, nil
+}
+
+// ReadFile parses one file. Blank lines and lines starting with # are
+// skipped, and a [command] line starts the settings of that command.
+func ReadFile(path string) ([]Setting, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var settings []Setting
+	var section string
+	scanner := bufio.NewScanner(file)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		source := fmt.Sprintf("%s:%d", path, n)
+		switch {
+		case line == "" || strings.HasPrefix(line, "#"):
+			continue
+		case strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]"):
+			section = strings.TrimSpace(line[1 : len(line)-1])
+			if section == "" {
+				return nil, &os.PathError{Op: "read config", Path: source, Err: errors.New(SectionErr)}
+			}
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+		if !ok || key == "" {
+			return nil, &os.PathError{Op: "read config", Path: source, Err: errors.New(SyntaxErr)}
+		}
+		settings = append(settings, Setting{Key: key, Value: unquote(value), Section: section, Source: source})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, &os.PathError{Op: "read config", Path: path, Err: err}
+	}
+	return settings, nil
+}
+
+// For is what applies to command, the settings outside of any
+// section and those of its section, in the order a later one
+// overrides an earlier one.
+func (c *Config) For(command string) []Setting {
+	var settings []Setting
+	for _, s := range c.Settings {
+		if s.Section == "" || s.Section == command {
+			settings = append(settings, s)
+		}
+	}
+	return settings
+}
+
+// unquote removes a pair of double quotes around value, so a value
+// can keep spaces at its ends or start with #.
+func unquote(value string) string {
+	if len(value) >= 2 && value[0] == '"' && value[len(value)-1] == '"' {
+		return value[1 : len(value)-1]
+	}
+	return value
+}
@@ -1,13 +1,96 @@
 package escapecode
 
+import (
+	"os"
+	"strings"
+
+	"golang.org/x/term"
+)
+
 const (
-	Reset   = "\033[0m"
-	Red     = "\033[31m"
-	Green   = "\033[32m"
-	Yellow  = "\033[33m"
-	Blue    = "\033[34m"
-	Magenta = "\033[35m"
-	Cyan    = "\033[36m"
-	White   = "\033[37m"
-    MvCrUpClrLine = "\033[A\033[2K\r"
-)
\ No newline at end of file
+	MvCrUpClrLine = "\033[A\033[2K\r"
+
+	// Color modes, auto colors a terminal unless NO_COLOR is set
+	// or TERM is dumb.
+	ColorAuto   = "auto"
+	ColorAlways = "always"
+	ColorNever  = "never"
+)
+
+// The colors are empty while colors are off, so they can be written
+// anywhere.
+var (
+	Reset   string
+	Red     string
+	Green   string
+	Yellow  string
+	Blue    string
+	Magenta string
+	Cyan    string
+	White   string
+
+	// stderrOn tells whether stderr takes the colors too, it may be
+	// redirected while stdout is a terminal.
+	stderrOn bool
+)
+
+func init() {
+	SetMode(ColorAuto)
+}
+
+// ValidMode reports whether mode is a color mode, empty is auto.
+func ValidMode(mode string) bool {
+	switch mode {
+	case "", ColorAuto, ColorAlways, ColorNever:
+		return true
+	}
+	return false
+}
+
+// SetMode turns the colors on or off, an invalid mode is auto.
+func SetMode(mode string) {
+	on := mode == ColorAlways
+	stderrOn = on
+	if mode != ColorAlways && mode != ColorNever {
+		on, stderrOn = colorTerminal(os.Stdout), colorTerminal(os.Stderr)
+	}
+	if !on {
+		Reset, Red, Green, Yellow, Blue, Magenta, Cyan, White = "", "", "", "", "", "", "", ""
+		return
+	}
+	Reset, Red, Green, Yellow = "\033[0m", "\033[31m", "\033[32m", "\033[33m"
+	Blue, Magenta, Cyan, White = "\033[34m", "\033[35m", "\033[36m", "\033[37m"
+}
+
+func colorTerminal(f *os.File) bool {
+	return os.Getenv("NO_COLOR") == "" && os.Getenv("TERM") != "dumb" && term.IsTerminal(int(f.Fd()))
+}
+
+// Stderr is s as it should be written to stderr, without its escape
+// sequences unless stderr takes colors.
+func Stderr(s string) string {
+	if stderrOn {
+		return s
+	}
+	return Strip(s)
+}
+
+// Strip removes the escape sequences from s, for output which is
+// not a terminal.
+func Strip(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\033' {
+			b.WriteByte(s[i])
+			continue
+		}
+		// skip up to the final byte of the sequence, a letter
+		for i++; i < len(s) && !isFinal(s[i]); i++ {
+		}
+	}
+	return b.String()
+}
+
+func isFinal(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
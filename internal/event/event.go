@@ -0,0 +1,136 @@
+package event
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+
+	esccode "github.com/ShuaibKhan786/cipher-project/internal/escapecode"
+	result "github.com/ShuaibKhan786/cipher-project/internal/result"
+)
+
+// Kinds of events. Every event is one JSON object on its own line,
+// its kind in the "event" field. Fields are only ever added.
+const (
+	KindStart    = "start"
+	KindProgress = "progress"
+	KindResult   = "result"
+	KindRemoved  = "removed"
+	KindSummary  = "summary"
+	KindError    = "error"
+)
+
+// Start opens a batch, Files is the number of result events to
+// expect.
+type Start struct {
+	Event   string `json:"event"`
+	Command string `json:"command"`
+	Files   int    `json:"files"`
+	Jobs    int    `json:"jobs"`
+}
+
+// Progress is sent whenever the whole percent of a file changes.
+type Progress struct {
+	Event   string  `json:"event"`
+	File    string  `json:"file"`
+	Percent float64 `json:"percent"`
+}
+
+// Result is the outcome of one file, Error is empty when its status
+// is ok.
+type Result struct {
+	Event  string        `json:"event"`
+	File   string        `json:"file"`
+	Output string        `json:"output,omitempty"`
+	Status result.Status `json:"status"`
+	Error  string        `json:"error,omitempty"`
+}
+
+// Removed is sent for every input removed once the batch is done,
+// or kept because it couldn't be removed, then Error tells why.
+type Removed struct {
+	Event string `json:"event"`
+	File  string `json:"file"`
+	Error string `json:"error,omitempty"`
+}
+
+// Summary closes a batch, Counts has every status key even when
+// its count is 0.
+type Summary struct {
+	Event     string         `json:"event"`
+	Files     int            `json:"files"`
+	Counts    map[string]int `json:"counts"`
+	ExitCode  int            `json:"exit_code"`
+	ElapsedMS int64          `json:"elapsed_ms"`
+}
+
+// Error is a failure which stops the whole run, such as an invalid
+// command line or an interrupt.
+type Error struct {
+	Event    string `json:"event"`
+	Error    string `json:"error"`
+	ExitCode int    `json:"exit_code"`
+}
+
+// Emitter writes events from any goroutine, one line each.
+type Emitter struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+func NewEmitter(w io.Writer) *Emitter {
+	return &Emitter{enc: json.NewEncoder(w)}
+}
+
+func (e *Emitter) emit(v any) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.enc.Encode(v)
+}
+
+func (e *Emitter) Start(command string, files, jobs int) {
+	e.emit(Start{Event: KindStart, Command: command, Files: files, Jobs: jobs})
+}
+
+func (e *Emitter) Progress(file string, percent float64) {
+	e.emit(Progress{Event: KindProgress, File: file, Percent: percent})
+}
+
+func (e *Emitter) Result(r result.Result, output string) {
+	ev := Result{Event: KindResult, File: r.Filename, Status: r.Status}
+	if r.Status == result.OK {
+		ev.Output = output
+	}
+	if r.Err != nil {
+		ev.Error = message(r.Err)
+	}
+	e.emit(ev)
+}
+
+func (e *Emitter) Removed(file string, err error) {
+	ev := Removed{Event: KindRemoved, File: file}
+	if err != nil {
+		ev.Error = message(err)
+	}
+	e.emit(ev)
+}
+
+func (e *Emitter) Summary(results []result.Result, elapsed time.Duration) {
+	e.emit(Summary{
+		Event:     KindSummary,
+		Files:     len(results),
+		Counts:    result.Counts(results),
+		ExitCode:  result.ExitCode(results),
+		ElapsedMS: elapsed.Milliseconds(),
+	})
+}
+
+func (e *Emitter) Error(err error, exitCode int) {
+	e.emit(Error{Event: KindError, Error: message(err), ExitCode: exitCode})
+}
+
+// message is the text of err without the colors meant for a terminal.
+func message(err error) string {
+	return esccode.Strip(err.Error())
+}
@@ -0,0 +1,566 @@
+package header
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+
+	kdf "github.com/ShuaibKhan786/cipher-project/internal/kdf"
+	secbuf "github.com/ShuaibKhan786/cipher-project/internal/secbuf"
+	"golang.org/x/crypto/hkdf"
+)
+
+// On-disk layout of an encrypted file:
+//
+//	magic(4) | version(1) | flags(1) | nonce(12) | slot count(1) | slots | chunks
+//
+// The content is encrypted with a random data key. Every slot holds a
+// copy of that data key wrapped by a key encryption key (KEK), so the
+// password can be changed by rewriting the slots only. The slot table
+// always has MaxSlots entries, so slots can be added and removed in
+// place as well.
+const (
+	Magic       = "EEAS"
+	Version     = 1
+	SaltSize    = 16
+	NonceSize   = 12
+	DataKeySize = 32
+	SlotSize    = 128
+	MaxSlots    = 8
+
+	// FlagVault marks a vault, many streams and an index after the
+	// header instead of a single stream
+	FlagVault = 1
+
+	SlotEmpty    = 0
+	SlotPassword = 1
+	SlotX25519   = 2
+
+	// secrets a password slot is derived from
+	SecretPassword = 1 << 0
+	SecretKeyfile  = 1 << 1
+
+	NotEncryptedErr       = "not an EncryptEase encrypted file, or one made before the file header: run EncryptEase migrate on it"
+	UnsupportedVersionErr = "unsupported file format version"
+	InvalidSlotErr        = "invalid key slot"
+	NoSlotErr             = "no usable key slot"
+	HeaderSizeErr         = "header size changed, can't rewrite in place"
+	SlotsFullErr          = "all key slots are in use"
+	LastSlotErr           = "can't remove the last key slot"
+	WrongPasswordErr      = "wrong password"
+	WrongIdentityErr      = "the private key doesn't match any recipient"
+	KeyfileRequiredErr    = "a keyfile is required to open this file"
+	SlotDamagedErr        = "the key is right but its key slot is damaged"
+	WrongKeyErr           = "the key doesn't open this key slot"
+)
+
+// Errors to tell apart with errors.Is, ReadFile wraps them in an
+// *os.PathError naming the file.
+var (
+	ErrNotEncrypted       = errors.New(NotEncryptedErr)
+	ErrUnsupportedVersion = errors.New(UnsupportedVersionErr)
+	ErrNoSlot             = errors.New(NoSlotErr)
+	ErrWrongPassword      = errors.New(WrongPasswordErr)
+	ErrWrongIdentity      = errors.New(WrongIdentityErr)
+	ErrKeyfileRequired    = errors.New(KeyfileRequiredErr)
+	ErrSlotDamaged        = errors.New(SlotDamagedErr)
+	ErrWrongKey           = errors.New(WrongKeyErr)
+)
+
+// Slot layouts, both padded to SlotSize:
+//
+//	password: type(1) | secrets(1) | salt(16) | iteration(4) | memory(4) | thread(1) | wrap nonce(12) | wrapped key(48)
+//	x25519:   type(1) | ephemeral public key(32) | wrap nonce(12) | wrapped key(48)
+//
+// The last checkSize bytes of a slot hold its key check, an HMAC of
+// the slot fields under a subkey of its KEK. It tells a wrong key from a
+// damaged slot, slots written before it have zeros there.
+const (
+	checkSize = 16
+	checkInfo = "EncryptEase key check"
+
+	prefixSize     = len(Magic) + 1 + 1 + NonceSize + 1
+	nonceOffset    = len(Magic) + 1 + 1
+	paramsSize     = 4 + 4 + 1
+	wrappedKeySize = DataKeySize + 16
+
+	x25519KeySize = 32
+	x25519Info    = "EncryptEase X25519 key wrap"
+)
+
+// Slot is one wrapped copy of the data key. Secrets, Salt and Params
+// are set for password slots, Ephemeral for X25519 recipient slots.
+type Slot struct {
+	Type       byte
+	Secrets    byte
+	Salt       []byte
+	Params     kdf.Params
+	Ephemeral  []byte
+	WrapNonce  []byte
+	WrappedKey []byte
+	Check      []byte
+}
+
+type Header struct {
+	Version byte
+	Flags   byte
+	Nonce   []byte
+	Slots   []Slot
+}
+
+func New(nonce []byte) *Header {
+	return &Header{
+		Version: Version,
+		Nonce:   nonce,
+		Slots:   make([]Slot, MaxSlots),
+	}
+}
+
+func NewDataKey() ([]byte, error) {
+	key := make([]byte, DataKeySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// NewPasswordSlot wraps dataKey with kek, the Argon2 key derived
+// from the password with salt and p.
+func NewPasswordSlot(dataKey, kek, salt []byte, p kdf.Params) (Slot, error) {
+	return NewSecretSlot(dataKey, kek, salt, p, SecretPassword)
+}
+
+// NewSecretSlot is NewPasswordSlot for a kek derived from a password,
+// a keyfile or both, as recorded by secrets.
+func NewSecretSlot(dataKey, kek, salt []byte, p kdf.Params, secrets byte) (Slot, error) {
+	slot := Slot{
+		Type:    SlotPassword,
+		Secrets: secrets,
+		Salt:    salt,
+		Params:  p,
+	}
+	if err := slot.wrap(dataKey, kek); err != nil {
+		return Slot{}, err
+	}
+	return slot, nil
+}
+
+// NewRecipientSlot wraps dataKey for the owner of an X25519 public
+// key. The KEK comes from an ephemeral key agreement, so nothing but
+// the public key is needed.
+func NewRecipientSlot(dataKey, publicKey []byte) (Slot, error) {
+	recipient, err := ecdh.X25519().NewPublicKey(publicKey)
+	if err != nil {
+		return Slot{}, err
+	}
+	ephemeral, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return Slot{}, err
+	}
+
+	slot := Slot{
+		Type:      SlotX25519,
+		Ephemeral: ephemeral.PublicKey().Bytes(),
+	}
+	kek, err := x25519KEK(ephemeral, recipient, slot.Ephemeral, publicKey)
+	if err != nil {
+		return Slot{}, err
+	}
+	defer secbuf.Wipe(kek)
+	if err := slot.wrap(dataKey, kek); err != nil {
+		return Slot{}, err
+	}
+	return slot, nil
+}
+
+// Unwrap returns the data key. If kek doesn't open the slot it
+// returns ErrSlotDamaged when the key check still matches kek, or
+// ErrWrongKey.
+func (s *Slot) Unwrap(kek []byte) ([]byte, error) {
+	if s.Type != SlotPassword && s.Type != SlotX25519 {
+		return nil, errors.New(InvalidSlotErr)
+	}
+	gcm, err := newgcm(kek)
+	if err != nil {
+		return nil, err
+	}
+	dataKey, err := gcm.Open(nil, s.WrapNonce, s.WrappedKey, s.additionalData())
+	if err == nil {
+		return dataKey, nil
+	}
+	if s.hasCheck() {
+		check, err := s.keyCheck(kek)
+		if err == nil && hmac.Equal(check, s.Check) {
+			return nil, ErrSlotDamaged
+		}
+	}
+	return nil, ErrWrongKey
+}
+
+// Unlock tries the password and the keyfile hash against every
+// password slot and returns the data key together with the index of
+// the slot that opened it. Slots needing a keyfile are skipped when
+// keyfile is nil.
+func (h *Header) Unlock(password, keyfile []byte, keys *kdf.Cache) ([]byte, int, error) {
+	found, skipped, damaged := false, false, false
+	for i := range h.Slots {
+		if h.Slots[i].Type != SlotPassword {
+			continue
+		}
+		if h.Slots[i].NeedsKeyfile() && keyfile == nil {
+			skipped = true
+			continue
+		}
+		found = true
+		secret := h.Slots[i].Secret(password, keyfile)
+		kek := keys.IDKey(secret, h.Slots[i].Salt, h.Slots[i].Params)
+		secbuf.Wipe(secret)
+		dataKey, err := h.Slots[i].Unwrap(kek)
+		if err == nil {
+			return dataKey, i, nil
+		}
+		damaged = damaged || errors.Is(err, ErrSlotDamaged)
+	}
+	if !found && skipped {
+		return nil, -1, ErrKeyfileRequired
+	}
+	if !found {
+		return nil, -1, ErrNoSlot
+	}
+	if damaged {
+		return nil, -1, ErrSlotDamaged
+	}
+	return nil, -1, ErrWrongPassword
+}
+
+func (s *Slot) NeedsPassword() bool {
+	return s.Type == SlotPassword && s.Secrets&SecretPassword != 0
+}
+
+func (s *Slot) NeedsKeyfile() bool {
+	return s.Type == SlotPassword && s.Secrets&SecretKeyfile != 0
+}
+
+// Secret is the Argon2 input of a password slot, made only of the
+// secrets the slot was created with.
+func (s *Slot) Secret(password, keyfile []byte) []byte {
+	if !s.NeedsPassword() {
+		password = nil
+	}
+	if !s.NeedsKeyfile() {
+		keyfile = nil
+	}
+	return kdf.Secret(password, keyfile)
+}
+
+// UnlockIdentity is Unlock for X25519 slots, using the private key
+// of a recipient.
+func (h *Header) UnlockIdentity(privateKey []byte) ([]byte, int, error) {
+	identity, err := ecdh.X25519().NewPrivateKey(privateKey)
+	if err != nil {
+		return nil, -1, err
+	}
+
+	found, damaged := false, false
+	for i := range h.Slots {
+		if h.Slots[i].Type != SlotX25519 {
+			continue
+		}
+		found = true
+		ephemeral, err := ecdh.X25519().NewPublicKey(h.Slots[i].Ephemeral)
+		if err != nil {
+			continue
+		}
+		kek, err := x25519KEK(identity, ephemeral, h.Slots[i].Ephemeral, identity.PublicKey().Bytes())
+		if err != nil {
+			continue
+		}
+		dataKey, err := h.Slots[i].Unwrap(kek)
+		secbuf.Wipe(kek)
+		if err == nil {
+			return dataKey, i, nil
+		}
+		damaged = damaged || errors.Is(err, ErrSlotDamaged)
+	}
+	if !found {
+		return nil, -1, ErrNoSlot
+	}
+	if damaged {
+		return nil, -1, ErrSlotDamaged
+	}
+	return nil, -1, ErrWrongIdentity
+}
+
+// AddSlot stores s in the first empty slot and returns its index.
+func (h *Header) AddSlot(s Slot) (int, error) {
+	for i := range h.Slots {
+		if h.Slots[i].Type == SlotEmpty {
+			h.Slots[i] = s
+			return i, nil
+		}
+	}
+	return -1, errors.New(SlotsFullErr)
+}
+
+// RemoveSlot empties the slot at index. The last used slot can't be
+// removed, otherwise nobody could open the file anymore.
+func (h *Header) RemoveSlot(index int) error {
+	if index < 0 || index >= len(h.Slots) || h.Slots[index].Type == SlotEmpty {
+		return errors.New(InvalidSlotErr)
+	}
+	if h.UsedSlots() == 1 {
+		return errors.New(LastSlotErr)
+	}
+	h.Slots[index] = Slot{}
+	return nil
+}
+
+func (h *Header) UsedSlots() int {
+	used := 0
+	for i := range h.Slots {
+		if h.Slots[i].Type != SlotEmpty {
+			used++
+		}
+	}
+	return used
+}
+
+// PasswordSlot returns the first password slot of the header.
+func (h *Header) PasswordSlot() (*Slot, error) {
+	for i := range h.Slots {
+		if h.Slots[i].Type == SlotPassword {
+			return &h.Slots[i], nil
+		}
+	}
+	return nil, ErrNoSlot
+}
+
+func (h *Header) Size() int64 {
+	return int64(prefixSize + len(h.Slots)*SlotSize)
+}
+
+func (h *Header) Write(w io.Writer) error {
+	_, err := w.Write(h.marshal())
+	return err
+}
+
+// Rewrite overwrites the header of filename in place, leaving the
+// encrypted content untouched. The header size must not change.
+func (h *Header) Rewrite(filename string) error {
+	old, err := ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	if old.Size() != h.Size() {
+		return errors.New(HeaderSizeErr)
+	}
+
+	file, err := os.OpenFile(filename, os.O_WRONLY, 0)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if _, err := file.WriteAt(h.marshal(), 0); err != nil {
+		return err
+	}
+	return file.Sync()
+}
+
+// WriteNonce overwrites the nonce of the header at the start of w
+// with h.Nonce. A vault changes it with every commit of its index.
+func (h *Header) WriteNonce(w io.WriterAt) error {
+	_, err := w.WriteAt(h.Nonce, int64(nonceOffset))
+	return err
+}
+
+func Read(r io.Reader) (*Header, error) {
+	prefix := make([]byte, prefixSize)
+	if _, err := io.ReadFull(r, prefix); err != nil {
+		return nil, ErrNotEncrypted
+	}
+	if string(prefix[:len(Magic)]) != Magic {
+		return nil, ErrNotEncrypted
+	}
+
+	off := len(Magic)
+	h := &Header{
+		Version: prefix[off],
+		Flags:   prefix[off+1],
+	}
+	if h.Version != Version {
+		return nil, ErrUnsupportedVersion
+	}
+	off += 2
+	h.Nonce = bytes.Clone(prefix[off : off+NonceSize])
+	off += NonceSize
+
+	slotCount := int(prefix[off])
+	buffer := make([]byte, SlotSize)
+	for i := 0; i < slotCount; i++ {
+		if _, err := io.ReadFull(r, buffer); err != nil {
+			return nil, ErrNotEncrypted
+		}
+		h.Slots = append(h.Slots, unmarshalSlot(buffer))
+	}
+	return h, nil
+}
+
+func ReadFile(filename string) (*Header, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	h, err := Read(file)
+	if err != nil {
+		return nil, &os.PathError{Op: "read header", Path: filename, Err: err}
+	}
+	return h, nil
+}
+
+func (h *Header) marshal() []byte {
+	buffer := make([]byte, 0, h.Size())
+	buffer = append(buffer, Magic...)
+	buffer = append(buffer, h.Version, h.Flags)
+	buffer = append(buffer, h.Nonce...)
+	buffer = append(buffer, byte(len(h.Slots)))
+	for i := range h.Slots {
+		buffer = append(buffer, h.Slots[i].marshal()...)
+	}
+	return buffer
+}
+
+func (s *Slot) marshal() []byte {
+	buffer := make([]byte, SlotSize)
+	buffer[0] = s.Type
+	off := 1
+	switch s.Type {
+	case SlotEmpty:
+		return buffer
+	case SlotPassword:
+		buffer[off] = s.Secrets
+		off++
+		copy(buffer[off:], s.Salt)
+		off += SaltSize
+		binary.BigEndian.PutUint32(buffer[off:], s.Params.Iteration)
+		binary.BigEndian.PutUint32(buffer[off+4:], s.Params.Memory)
+		buffer[off+8] = s.Params.Thread
+		off += paramsSize
+	case SlotX25519:
+		copy(buffer[off:], s.Ephemeral)
+		off += x25519KeySize
+	}
+	copy(buffer[off:], s.WrapNonce)
+	off += NonceSize
+	copy(buffer[off:], s.WrappedKey)
+	copy(buffer[SlotSize-checkSize:], s.Check)
+	return buffer
+}
+
+func unmarshalSlot(buffer []byte) Slot {
+	s := Slot{Type: buffer[0]}
+	off := 1
+	switch s.Type {
+	case SlotPassword:
+		s.Secrets = buffer[off]
+		off++
+		s.Salt = bytes.Clone(buffer[off : off+SaltSize])
+		off += SaltSize
+		s.Params.Iteration = binary.BigEndian.Uint32(buffer[off:])
+		s.Params.Memory = binary.BigEndian.Uint32(buffer[off+4:])
+		s.Params.Thread = buffer[off+8]
+		off += paramsSize
+	case SlotX25519:
+		s.Ephemeral = bytes.Clone(buffer[off : off+x25519KeySize])
+		off += x25519KeySize
+	default:
+		// empty, or a slot type this version doesn't know
+		return s
+	}
+	s.WrapNonce = bytes.Clone(buffer[off : off+NonceSize])
+	off += NonceSize
+	s.WrappedKey = bytes.Clone(buffer[off : off+wrappedKeySize])
+	s.Check = bytes.Clone(buffer[SlotSize-checkSize:])
+	return s
+}
+
+// additionalData is the slot up to the wrapped key, so none of
+// its fields can be changed without breaking the unwrap.
+func (s *Slot) additionalData() []byte {
+	off := 1 + NonceSize
+	switch s.Type {
+	case SlotPassword:
+		off += 1 + SaltSize + paramsSize
+	case SlotX25519:
+		off += x25519KeySize
+	}
+	return s.marshal()[:off]
+}
+
+func (s *Slot) wrap(dataKey, kek []byte) error {
+	s.WrapNonce = make([]byte, NonceSize)
+	if _, err := rand.Read(s.WrapNonce); err != nil {
+		return err
+	}
+	gcm, err := newgcm(kek)
+	if err != nil {
+		return err
+	}
+	s.WrappedKey = gcm.Seal(nil, s.WrapNonce, dataKey, s.additionalData())
+	s.Check, err = s.keyCheck(kek)
+	return err
+}
+
+// keyCheck is the HMAC of the slot fields before its wrap nonce,
+// under a subkey of kek so it reveals nothing about the KEK itself.
+// The wrap nonce and the wrapped key are left out, damage to them
+// must not look like a wrong key.
+func (s *Slot) keyCheck(kek []byte) ([]byte, error) {
+	subkey := make([]byte, sha256.Size)
+	defer secbuf.Wipe(subkey)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, kek, nil, []byte(checkInfo)), subkey); err != nil {
+		return nil, err
+	}
+	mac := hmac.New(sha256.New, subkey)
+	ad := s.additionalData()
+	mac.Write(ad[:len(ad)-NonceSize])
+	return mac.Sum(nil)[:checkSize], nil
+}
+
+func (s *Slot) hasCheck() bool {
+	return len(s.Check) == checkSize && !bytes.Equal(s.Check, make([]byte, checkSize))
+}
+
+// x25519KEK derives the key encryption key from the shared secret,
+// bound to both public keys of the exchange.
+func x25519KEK(private *ecdh.PrivateKey, public *ecdh.PublicKey, ephemeral, recipient []byte) ([]byte, error) {
+	shared, err := private.ECDH(public)
+	if err != nil {
+		return nil, err
+	}
+	defer secbuf.Wipe(shared)
+	salt := append(bytes.Clone(ephemeral), recipient...)
+	kek := make([]byte, kdf.KeyLength)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, shared, salt, []byte(x25519Info)), kek); err != nil {
+		return nil, err
+	}
+	return kek, nil
+}
+
+func newgcm(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,106 @@
+package kdf
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	secbuf "github.com/ShuaibKhan786/cipher-project/internal/secbuf"
+
+	argon "golang.org/x/crypto/argon2"
+)
+
+const (
+	KeyLength = 32 // i.e. 256 bits
+)
+
+// Params are the Argon2id cost parameters, stored alongside the salt
+// so a file can still be opened if the defaults change later.
+type Params struct {
+	Iteration uint32
+	Memory    uint32
+	Thread    uint8
+}
+
+var DefaultParams = Params{
+	Iteration: 1,
+	Memory:    64 * 1024,
+	Thread:    4,
+}
+
+func IDKey(password, salt []byte, p Params) []byte {
+	return argon.IDKey(password, salt, p.Iteration, p.Memory, p.Thread, KeyLength)
+}
+
+// Secret is the Argon2 input made of the password followed by the
+// hash of the keyfile, either one can be missing.
+func Secret(password, keyfile []byte) []byte {
+	return append(bytes.Clone(password), keyfile...)
+}
+
+// HashKeyfile returns the SHA-256 of the keyfile contents, so a
+// keyfile of any size adds a fixed 32 bytes to the secret.
+func HashKeyfile(filename string) ([]byte, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return nil, err
+	}
+	return hash.Sum(nil), nil
+}
+
+// Cache remembers derived keys, so a batch of files sharing a salt
+// pays for Argon2 only once. The keys are kept in secure buffers
+// until Destroy. A key is found by an HMAC of its password under a
+// random key of the cache, so the ids left in memory can't be used
+// to guess the passwords once that key is destroyed.
+type Cache struct {
+	mu    sync.Mutex
+	keys  map[string]*secbuf.Buffer
+	idKey *secbuf.Buffer
+}
+
+func NewCache() *Cache {
+	idKey := secbuf.New(sha256.Size)
+	if _, err := rand.Read(idKey.Bytes()); err != nil {
+		panic(err)
+	}
+	return &Cache{keys: make(map[string]*secbuf.Buffer), idKey: idKey}
+}
+
+func (c *Cache) IDKey(password, salt []byte, p Params) []byte {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	mac := hmac.New(sha256.New, c.idKey.Bytes())
+	mac.Write(password)
+	id := fmt.Sprintf("%x:%x:%d:%d:%d", mac.Sum(nil), salt, p.Iteration, p.Memory, p.Thread)
+	if key, ok := c.keys[id]; ok {
+		return key.Bytes()
+	}
+	key := secbuf.From(IDKey(password, salt, p))
+	c.keys[id] = key
+	return key.Bytes()
+}
+
+// Destroy wipes every cached key and the id key, the keys returned
+// by IDKey can't be used after it.
+func (c *Cache) Destroy() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for id, key := range c.keys {
+		key.Destroy()
+		delete(c.keys, id)
+	}
+	c.idKey.Destroy()
+}
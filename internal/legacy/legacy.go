@@ -0,0 +1,111 @@
+package legacy
+
+import (
+	"bufio"
+	"crypto/aes"
+	"crypto/cipher"
+	"errors"
+	"io"
+
+	aescipher "github.com/ShuaibKhan786/cipher-project/internal/cipher"
+	header "github.com/ShuaibKhan786/cipher-project/internal/header"
+	kdf "github.com/ShuaibKhan786/cipher-project/internal/kdf"
+	secbuf "github.com/ShuaibKhan786/cipher-project/internal/secbuf"
+)
+
+// Files written before the header have no magic or version:
+//
+//	salt(16) | nonce(12) | chunks
+//
+// Every chunk is up to aescipher.ChunkSize bytes sealed with AES-256-GCM under
+// the same nonce and no additional data, the key is derived from the
+// password alone with Params. Nothing marks the final chunk, so a
+// file cut right after a chunk can't be told from a complete one.
+const (
+	SaltSize   = 16
+	NonceSize  = 12
+	HeaderSize = SaltSize + NonceSize
+)
+
+const CurrentFormatErr = "already in the current format"
+
+// ErrCurrentFormat is a file which has a header, it needs no
+// migration.
+var ErrCurrentFormat = errors.New(CurrentFormatErr)
+
+// Params are the Argon2id parameters every legacy file was derived
+// with.
+var Params = kdf.Params{
+	Iteration: 1,
+	Memory:    64 * 1024,
+	Thread:    4,
+}
+
+// ReadHeader reads the salt and nonce at the start of a legacy file,
+// a file too short for them is not one.
+func ReadHeader(r io.Reader) (salt, nonce []byte, err error) {
+	buffer := make([]byte, HeaderSize)
+	if _, err := io.ReadFull(r, buffer); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil, nil, header.ErrNotEncrypted
+		}
+		return nil, nil, err
+	}
+	if string(buffer[:len(header.Magic)]) == header.Magic {
+		return nil, nil, ErrCurrentFormat
+	}
+	return buffer[:SaltSize], buffer[SaltSize:], nil
+}
+
+// Reader decrypts the chunks of a legacy file, r must be positioned
+// right after its header. A first chunk which doesn't open is taken
+// for a wrong password, a later one for damaged data.
+type Reader struct {
+	r      *bufio.Reader
+	gcm    cipher.AEAD
+	nonce  []byte
+	buffer []byte
+	plain  []byte
+	chunk  uint64
+}
+
+func NewReader(r io.Reader, key *secbuf.Buffer, nonce []byte) (*Reader, error) {
+	block, err := aes.NewCipher(key.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &Reader{
+		r:      bufio.NewReader(r),
+		gcm:    gcm,
+		nonce:  nonce,
+		buffer: make([]byte, aescipher.ChunkSize+gcm.Overhead()),
+	}, nil
+}
+
+func (r *Reader) Read(p []byte) (int, error) {
+	for len(r.plain) == 0 {
+		n, err := io.ReadFull(r.r, r.buffer)
+		if err == io.EOF {
+			return 0, io.EOF
+		}
+		if err != nil && err != io.ErrUnexpectedEOF {
+			return 0, err
+		}
+		plain, err := r.gcm.Open(r.buffer[:0], r.nonce, r.buffer[:n], nil)
+		if err != nil {
+			if r.chunk == 0 {
+				return 0, header.ErrWrongPassword
+			}
+			return 0, &aescipher.ChunkError{Chunk: r.chunk, Err: aescipher.ErrAuthentication}
+		}
+		r.chunk++
+		r.plain = plain
+	}
+	n := copy(p, r.plain)
+	r.plain = r.plain[n:]
+	return n, nil
+}
@@ -0,0 +1,60 @@
+package passphrase
+
+import (
+	"crypto/rand"
+	_ "embed"
+	"errors"
+	"math"
+	"math/big"
+	"strings"
+)
+
+// The wordlist has 6^4 words, each one indexed by four dice rolls,
+// so a passphrase can also be made with real dice.
+//
+//go:embed wordlist.txt
+var wordlist string
+
+const (
+	DefaultWords = 7
+	MinWords     = 4
+	MaxWords     = 20
+	Separator    = "-"
+
+	WordCountErr = "the number of words must be between 4 and 20"
+)
+
+var words = parseWordlist(wordlist)
+
+// Generate picks n words uniformly at random with crypto/rand.
+func Generate(n int) (string, error) {
+	if n < MinWords || n > MaxWords {
+		return "", errors.New(WordCountErr)
+	}
+
+	picked := make([]string, n)
+	max := big.NewInt(int64(len(words)))
+	for i := range picked {
+		index, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		picked[i] = words[index.Int64()]
+	}
+	return strings.Join(picked, Separator), nil
+}
+
+// Entropy is the strength in bits of an n-word passphrase, assuming
+// the attacker knows the wordlist.
+func Entropy(n int) float64 {
+	return float64(n) * math.Log2(float64(len(words)))
+}
+
+func parseWordlist(content string) []string {
+	var list []string
+	for _, line := range strings.Split(strings.TrimSpace(content), "\n") {
+		_, word, _ := strings.Cut(line, "\t")
+		list = append(list, word)
+	}
+	return list
+}
@@ -0,0 +1,245 @@
+package progress
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	esccode "github.com/ShuaibKhan786/cipher-project/internal/escapecode"
+	"golang.org/x/term"
+)
+
+const (
+	// redrawInterval bounds the redraws on a terminal, logInterval
+	// is the time between two lines when the output is not one.
+	redrawInterval = 100 * time.Millisecond
+	logInterval    = 2 * time.Second
+
+	defaultWidth = 80
+	minNameWidth = 12
+	// statsWidth is the room taken by the percent, rate and ETA
+	// after the name of a file.
+	statsWidth = 36
+)
+
+// The rate of a file is measured from its first update, base is
+// what it had read by then.
+type file struct {
+	name     string
+	size     int64
+	read     int64
+	base     int64
+	started  time.Time
+	active   bool
+	finished bool
+}
+
+// Renderer shows the progress of a batch, the overall line first
+// then one line for every file in progress, in the order of the
+// batch. On a terminal it redraws in place and fits the width,
+// anywhere else it writes a plain line every few seconds.
+type Renderer struct {
+	w     io.Writer
+	fd    int
+	tty   bool
+	files []*file
+	index map[string]int
+	total int64
+	done  int
+	start time.Time
+	drawn time.Time
+	lines int
+}
+
+// New prepares the renderer of filenames, their sizes give the
+// overall total. w is a terminal when it is one of the standard
+// files attached to one.
+func New(w io.Writer, filenames []string) *Renderer {
+	r := &Renderer{
+		w:     w,
+		files: make([]*file, len(filenames)),
+		index: make(map[string]int, len(filenames)),
+		start: time.Now(),
+	}
+	if f, ok := w.(*os.File); ok && term.IsTerminal(int(f.Fd())) {
+		r.fd, r.tty = int(f.Fd()), true
+	}
+	for i, filename := range filenames {
+		r.files[i] = &file{name: filename}
+		r.index[filename] = i
+		if info, err := os.Stat(filename); err == nil {
+			r.files[i].size = info.Size()
+			r.total += info.Size()
+		}
+	}
+	return r
+}
+
+// Update records that read bytes of the size of filename are done.
+func (r *Renderer) Update(filename string, read, size int64) {
+	i, ok := r.index[filename]
+	if !ok {
+		return
+	}
+	f := r.files[i]
+	if !f.active && !f.finished {
+		f.active, f.started, f.base = true, time.Now(), read
+	}
+	if size > 0 && size != f.size {
+		r.total += size - f.size
+		f.size = size
+	}
+	f.read = read
+	r.draw(false)
+}
+
+// Finish records that filename is done, whether it failed or not.
+func (r *Renderer) Finish(filename string) {
+	i, ok := r.index[filename]
+	if !ok || r.files[i].finished {
+		return
+	}
+	f := r.files[i]
+	f.active, f.finished = false, true
+	f.read = f.size
+	r.done++
+	r.draw(false)
+}
+
+// Close draws the final state.
+func (r *Renderer) Close() {
+	r.draw(true)
+}
+
+func (r *Renderer) draw(force bool) {
+	now := time.Now()
+	interval := logInterval
+	if r.tty {
+		interval = redrawInterval
+	}
+	if !force && !r.drawn.IsZero() && now.Sub(r.drawn) < interval {
+		return
+	}
+	r.drawn = now
+
+	if !r.tty {
+		fmt.Fprintln(r.w, r.overall(now))
+		return
+	}
+
+	width, height := defaultWidth, 0
+	if w, h, err := term.GetSize(r.fd); err == nil && w > 0 {
+		width, height = w, h
+	}
+	lines := []string{esccode.Cyan + fit(r.overall(now), width) + esccode.Reset}
+	var active []*file
+	for _, f := range r.files {
+		if f.active {
+			active = append(active, f)
+		}
+	}
+	// keep the overall line and a line for the rest on the screen
+	rest := 0
+	if height > 2 && len(active) > height-2 {
+		rest = len(active) - (height - 3)
+		active = active[:height-3]
+	}
+	for _, f := range active {
+		lines = append(lines, r.fileLine(f, now, width))
+	}
+	if rest != 0 {
+		lines = append(lines, fmt.Sprintf("  ... and %d more", rest))
+	}
+
+	var b strings.Builder
+	for ; r.lines > 0; r.lines-- {
+		b.WriteString(esccode.MvCrUpClrLine)
+	}
+	for _, line := range lines {
+		b.WriteString(line + "\n")
+	}
+	io.WriteString(r.w, b.String())
+	r.lines = len(lines)
+}
+
+// overall is the files done, the bytes done of the total, the rate
+// since the start and the time left at that rate.
+func (r *Renderer) overall(now time.Time) string {
+	var read int64
+	for _, f := range r.files {
+		if f.active || f.finished {
+			read += f.read
+		}
+	}
+	elapsed := now.Sub(r.start)
+	rate := Rate(read, elapsed)
+	return fmt.Sprintf("%d/%d files  %s/%s  %s/s  ETA %s",
+		r.done, len(r.files), Bytes(read), Bytes(r.total), Bytes(int64(rate)), ETA(r.total-read, rate))
+}
+
+func (r *Renderer) fileLine(f *file, now time.Time, width int) string {
+	rate := Rate(f.read-f.base, now.Sub(f.started))
+	percent := 100.0
+	if f.size > 0 {
+		percent = 100 * float64(f.read) / float64(f.size)
+	}
+	stats := fmt.Sprintf("%4.0f%%  %9s/s  ETA %s", percent, Bytes(int64(rate)), ETA(f.size-f.read, rate))
+	nameWidth := max(width-statsWidth-4, minNameWidth)
+	return fmt.Sprintf("  %-*s  %s", nameWidth, Shorten(f.name, nameWidth), stats)
+}
+
+// Rate is bytes per second.
+func Rate(bytes int64, elapsed time.Duration) float64 {
+	if elapsed <= 0 {
+		return 0
+	}
+	return float64(bytes) / elapsed.Seconds()
+}
+
+// ETA is the time needed for the bytes left at rate, "--" while
+// the rate is unknown.
+func ETA(left int64, rate float64) string {
+	if left <= 0 {
+		return "0s"
+	}
+	if rate <= 0 {
+		return "--"
+	}
+	return time.Duration(float64(left) / rate * float64(time.Second)).Round(time.Second).String()
+}
+
+// Bytes is n in binary units, such as 1.5 MiB.
+func Bytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	value, prefix := float64(n)/unit, 0
+	for ; value >= unit && prefix < 4; prefix++ {
+		value /= unit
+	}
+	return fmt.Sprintf("%.1f %ciB", value, "KMGTP"[prefix])
+}
+
+// Shorten cuts the middle of name to fit width characters, so both
+// the directory and the file name stay visible.
+func Shorten(name string, width int) string {
+	if utf8.RuneCountInString(name) <= width {
+		return name
+	}
+	runes := []rune(name)
+	head := (width - 1) / 2
+	tail := width - 1 - head
+	return string(runes[:head]) + "…" + string(runes[len(runes)-tail:])
+}
+
+// fit cuts line to width characters.
+func fit(line string, width int) string {
+	if utf8.RuneCountInString(line) <= width {
+		return line
+	}
+	return string([]rune(line)[:width])
+}
@@ -0,0 +1,112 @@
+package recipient
+
+import (
+	"crypto/ecdh"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"os"
+	"strings"
+
+	secbuf "github.com/ShuaibKhan786/cipher-project/internal/secbuf"
+)
+
+// Keys are stored as a single line of text, the prefix tells
+// public and private keys apart so they can't be swapped by mistake.
+const (
+	PublicKeyPrefix  = "encryptease-pub-"
+	PrivateKeyPrefix = "encryptease-sec-"
+	PublicKeyExt     = ".pub"
+
+	InvalidPublicKeyErr  = "invalid public key"
+	InvalidPrivateKeyErr = "invalid private key"
+	KeyFileExistsErr     = "key file already exists"
+)
+
+// GenerateKeyPair writes a new X25519 private key to filename and the
+// matching public key to filename.pub. Existing files are never
+// overwritten.
+func GenerateKeyPair(filename string) (string, error) {
+	private, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return "", err
+	}
+
+	for _, name := range []string{filename, filename + PublicKeyExt} {
+		if _, err := os.Stat(name); err == nil {
+			return "", errors.New(KeyFileExistsErr)
+		}
+	}
+
+	public := encode(PublicKeyPrefix, private.PublicKey().Bytes())
+	if err := writeKeyFile(filename, encode(PrivateKeyPrefix, private.Bytes()), 0600); err != nil {
+		return "", err
+	}
+	if err := writeKeyFile(filename+PublicKeyExt, public, 0644); err != nil {
+		os.Remove(filename)
+		return "", err
+	}
+	return public, nil
+}
+
+// ParsePublicKey accepts either the encoded key itself or the path
+// of a file holding it.
+func ParsePublicKey(recipient string) ([]byte, error) {
+	text := recipient
+	if !strings.HasPrefix(recipient, PublicKeyPrefix) {
+		content, err := os.ReadFile(recipient)
+		if err != nil {
+			return nil, err
+		}
+		text = string(content)
+	}
+
+	key, err := decode(PublicKeyPrefix, text)
+	if err != nil {
+		return nil, errors.New(InvalidPublicKeyErr)
+	}
+	return key, nil
+}
+
+func ReadPrivateKey(filename string) ([]byte, error) {
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer secbuf.Wipe(content)
+
+	key, err := decode(PrivateKeyPrefix, string(content))
+	if err != nil {
+		return nil, errors.New(InvalidPrivateKeyErr)
+	}
+	return key, nil
+}
+
+func encode(prefix string, key []byte) string {
+	return prefix + base64.RawURLEncoding.EncodeToString(key)
+}
+
+func decode(prefix, text string) ([]byte, error) {
+	text = strings.TrimSpace(text)
+	if !strings.HasPrefix(text, prefix) {
+		return nil, errors.New(prefix)
+	}
+	key, err := base64.RawURLEncoding.DecodeString(text[len(prefix):])
+	if err != nil {
+		return nil, err
+	}
+	if len(key) != 32 {
+		return nil, errors.New(prefix)
+	}
+	return key, nil
+}
+
+func writeKeyFile(filename, key string, perm os.FileMode) error {
+	file, err := os.OpenFile(filename, os.O_WRONLY|os.O_CREATE|os.O_EXCL, perm)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	_, err = file.WriteString(key + "\n")
+	return err
+}
@@ -0,0 +1,213 @@
+package result
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	aescipher "github.com/ShuaibKhan786/cipher-project/internal/cipher"
+	esccode "github.com/ShuaibKhan786/cipher-project/internal/escapecode"
+	header "github.com/ShuaibKhan786/cipher-project/internal/header"
+	salting "github.com/ShuaibKhan786/cipher-project/internal/salting"
+)
+
+// Status is the outcome of one file of a batch.
+type Status int
+
+const (
+	OK Status = iota
+	Skipped
+	AuthFailed
+	IOError
+	Truncated
+	Corrupted
+	// Unsupported is a file with no header, or one of a newer
+	// version, which can't be opened rather than left alone.
+	Unsupported
+)
+
+// Exit codes of a batch, 1 is left for fatal errors and interrupts.
+const (
+	ExitOK            = 0
+	ExitUsage         = 2
+	ExitPartial       = 3
+	ExitFailure       = 4
+	ExitWrongPassword = 5
+)
+
+var statusNames = [...]string{
+	OK:          "ok",
+	Skipped:     "skipped",
+	AuthFailed:  "auth failed",
+	IOError:     "I/O error",
+	Truncated:   "truncated",
+	Corrupted:   "corrupted",
+	Unsupported: "unsupported",
+}
+
+// statusKeys name the statuses in machine-readable output, they
+// don't change.
+var statusKeys = [...]string{
+	OK:          "ok",
+	Skipped:     "skipped",
+	AuthFailed:  "auth_failed",
+	IOError:     "io_error",
+	Truncated:   "truncated",
+	Corrupted:   "corrupted",
+	Unsupported: "unsupported",
+}
+
+func (s Status) String() string {
+	return statusNames[s]
+}
+
+// Key is the stable name of the status.
+func (s Status) Key() string {
+	return statusKeys[s]
+}
+
+// MarshalText encodes the status as its key.
+func (s Status) MarshalText() ([]byte, error) {
+	return []byte(s.Key()), nil
+}
+
+// Counts is the number of files of every status, by key.
+func Counts(results []Result) map[string]int {
+	counts := make(map[string]int, len(statusKeys))
+	for _, key := range statusKeys {
+		counts[key] = 0
+	}
+	for _, r := range results {
+		counts[r.Status.Key()]++
+	}
+	return counts
+}
+
+// Result is the outcome of one file, Err is nil when it is OK.
+type Result struct {
+	Filename string
+	Status   Status
+	Err      error
+}
+
+type skipError struct {
+	err error
+}
+
+func (e skipError) Error() string { return e.err.Error() }
+func (e skipError) Unwrap() error { return e.err }
+
+// Skip marks err as a file which is left alone on purpose, rather
+// than one which failed.
+func Skip(err error) error {
+	return skipError{err: err}
+}
+
+// New is the result of filename for the error its operation returned.
+func New(filename string, err error) Result {
+	return Result{Filename: filename, Status: classify(err), Err: err}
+}
+
+func classify(err error) Status {
+	if err == nil {
+		return OK
+	}
+	var skip skipError
+	if errors.As(err, &skip) {
+		return Skipped
+	}
+	switch {
+	case errors.Is(err, aescipher.ErrTruncated), errors.Is(err, io.ErrUnexpectedEOF):
+		return Truncated
+	case errors.Is(err, header.ErrWrongPassword), errors.Is(err, header.ErrWrongIdentity),
+		errors.Is(err, header.ErrKeyfileRequired), errors.Is(err, header.ErrNoSlot),
+		errors.Is(err, salting.ErrSaltMismatch):
+		return AuthFailed
+	case errors.Is(err, aescipher.ErrAuthentication), errors.Is(err, header.ErrSlotDamaged):
+		return Corrupted
+	case errors.Is(err, header.ErrNotEncrypted), errors.Is(err, header.ErrUnsupportedVersion):
+		return Unsupported
+	}
+	return IOError
+}
+
+// ExitCode is 0 when every file is OK or skipped, ExitPartial when
+// only some failed and ExitFailure when none went through, or
+// ExitWrongPassword if the key opened none of them. Corrupted files
+// were opened with the right key, they are plain failures.
+func ExitCode(results []Result) int {
+	var ok, failed, auth int
+	for _, r := range results {
+		switch r.Status {
+		case OK:
+			ok++
+		case Skipped:
+		case AuthFailed:
+			auth++
+			failed++
+		default:
+			failed++
+		}
+	}
+	switch {
+	case failed == 0:
+		return ExitOK
+	case ok != 0:
+		return ExitPartial
+	case auth == failed:
+		return ExitWrongPassword
+	}
+	return ExitFailure
+}
+
+// Succeeded are the files which went through.
+func Succeeded(results []Result) []string {
+	var filenames []string
+	for _, r := range results {
+		if r.Status == OK {
+			filenames = append(filenames, r.Filename)
+		}
+	}
+	return filenames
+}
+
+// PrintSummary writes the number of files of each status, then
+// every file which didn't go through with the reason.
+func PrintSummary(w io.Writer, results []Result) {
+	counts := make([]int, len(statusNames))
+	for _, r := range results {
+		counts[r.Status]++
+	}
+
+	fmt.Fprintln(w)
+	for status, count := range counts {
+		if count != 0 {
+			fmt.Fprintf(w, "%s\t%-13s%6d%s\n", color(Status(status)), Status(status), count, esccode.Reset)
+		}
+	}
+	for _, r := range results {
+		if r.Status != OK {
+			fmt.Fprintf(w, "%s\t%-13s%v%s\n", color(r.Status), r.Status, fileError(r), esccode.Reset)
+		}
+	}
+}
+
+// fileError is the error of r naming its file, unless it already does.
+func fileError(r Result) error {
+	var pathErr *os.PathError
+	if errors.As(r.Err, &pathErr) {
+		return r.Err
+	}
+	return &os.PathError{Op: r.Status.String(), Path: r.Filename, Err: r.Err}
+}
+
+func color(s Status) string {
+	switch s {
+	case OK:
+		return esccode.Green
+	case Skipped:
+		return esccode.Yellow
+	}
+	return esccode.Red
+}
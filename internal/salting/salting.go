@@ -6,14 +6,17 @@ import (
 	"fmt"
 	"os"
 
-	cliarg "github.com/ShuaibKhan786/cipher-project/internal/cmdlineargs"
+	header "github.com/ShuaibKhan786/cipher-project/internal/header"
 )
 
 const (
-	InvalidPasswordErr = "invalid password"
-	FileReadErr        = "error reading file"
+	SaltMismatchErr = "the files were encrypted with different passwords"
 )
 
+// ErrSaltMismatch is wrapped in an *os.PathError naming a file whose
+// salt differs from the ones the passwords of its batch opened.
+var ErrSaltMismatch = errors.New(SaltMismatchErr)
+
 type Salt []byte
 type Nonce []byte
 type NNonce []Nonce
@@ -34,75 +37,74 @@ func NewSaltNoncePair(saltSize, nonceSize, numOfFiles int) *SaltNoncePair {
 	}
 }
 
-func (pair *SaltNoncePair) GenerateSaltNoncePair(md *cliarg.ArgsMetaData) error {
-	if md.Operation == cliarg.EncryptionOp {
-		return generateRandomSaltNoncePair(pair)
-	}else {
-		return extractSaltNoncePair(md,pair)
+func NewRandomNonce(nonceSize int) (Nonce, error) {
+	nonce := make(Nonce, nonceSize)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
 	}
+	return nonce, nil
 }
 
-func generateRandomSaltNoncePair(pair *SaltNoncePair) error {
-	if _,err := rand.Read(pair.S); err != nil {
+func NewRandomSalt(saltSize int) (Salt, error) {
+	salt := make(Salt, saltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	return salt, nil
+}
+
+// GenerateSaltNoncePair fills the pair with a random salt and a
+// random nonce for every file to encrypt.
+func (pair *SaltNoncePair) GenerateSaltNoncePair() error {
+	if _, err := rand.Read(pair.S); err != nil {
 		return err
 	}
 
-	for i := 0 ; i < len(pair.NN) ; i++ {
-		if _,err := rand.Read(pair.NN[i]); err != nil {
+	for i := 0; i < len(pair.NN); i++ {
+		if _, err := rand.Read(pair.NN[i]); err != nil {
 			return err
 		}
 	}
 	return nil
 }
 
-func extractSaltNoncePair(md *cliarg.ArgsMetaData, pair *SaltNoncePair) error {
-	extractedSaltSize := len(pair.S)
-	salt1 := make(Salt, extractedSaltSize)
-	salt2 := make(Salt, extractedSaltSize)
-	for i, v := range md.FileNames {
-		if i == 0 {
-			if err := extractSaltNonce(&salt1, &pair.NN[i], v); err != nil {
-				return err
-			}
-			if len(md.FileNames) == 1 {
-				break
-			}
-		} else {
-			fmt.Println(v)
-			if err := extractSaltNonce(&salt2, &pair.NN[i], v); err != nil {
-				return err
-			}
-			if !compareSalt(&salt1, &salt2) {
-				return errors.New(InvalidPasswordErr)
-			}
-		}
-	}
-	copy(pair.S, salt1)
-	return nil
+// KeyGroup is files whose password slots have the same salts and
+// Argon2 parameters, so the keys derived for one open them all.
+type KeyGroup struct {
+	FileNames []string
 }
 
-func extractSaltNonce(s *Salt, n *Nonce, filename string) error {
-	buffer := make([]byte,len(*s)+len(*n))
-	file, err := os.Open(filename)
-	if err != nil {
-		return errors.New(FileReadErr)
-	}
-	defer file.Close()
-
-	_, err = file.Read(buffer)
-	if err != nil {
-		return errors.New(FileReadErr)
+// GroupByKeyParams splits filenames into key groups, in the order
+// they first appear. Files whose header can't be read are left out,
+// opening them reports why.
+func GroupByKeyParams(filenames []string) []KeyGroup {
+	var groups []KeyGroup
+	index := make(map[string]int)
+	for _, filename := range filenames {
+		hdr, err := header.ReadFile(filename)
+		if err != nil {
+			continue
+		}
+		var key []byte
+		for _, slot := range hdr.Slots {
+			if slot.Type == header.SlotPassword {
+				key = append(key, slot.Salt...)
+				key = append(key, fmt.Sprintf(":%d:%d:%d:%d;", slot.Secrets, slot.Params.Iteration, slot.Params.Memory, slot.Params.Thread)...)
+			}
+		}
+		i, ok := index[string(key)]
+		if !ok {
+			i = len(groups)
+			index[string(key)] = i
+			groups = append(groups, KeyGroup{})
+		}
+		groups[i].FileNames = append(groups[i].FileNames, filename)
 	}
-	copy(*s,buffer[:len(*s)])
-	copy(*n,buffer[len(*s):])
-	return nil
+	return groups
 }
 
-func compareSalt(salt1, salt2 *Salt) bool {
-	for i, v := range *salt1 {
-		if v != (*salt2)[i] {
-			return false
-		}
-	}
-	return true
+// SaltMismatch is the error of filename when the passwords of its
+// batch opened other key groups but not the one of filename.
+func SaltMismatch(filename string) error {
+	return &os.PathError{Op: "compare salt", Path: filename, Err: ErrSaltMismatch}
 }
@@ -0,0 +1,63 @@
+package secbuf
+
+import "runtime"
+
+// Buffer holds key material outside of the garbage collected heap
+// where the platform allows it, locked in RAM so it is never written
+// to swap, and zeroed by Destroy. Keep a *Buffer instead of copying
+// its bytes around, every copy is one more place to wipe.
+type Buffer struct {
+	data   []byte
+	mem    []byte
+	mapped bool
+	locked bool
+}
+
+// New returns a zeroed buffer of size bytes. Failing to lock the
+// memory is not an error, the buffer is still wiped on Destroy.
+func New(size int) *Buffer {
+	b := &Buffer{}
+	b.mem, b.mapped, b.locked = alloc(size)
+	b.data = b.mem[:size]
+	return b
+}
+
+// From moves src into a new buffer and wipes src.
+func From(src []byte) *Buffer {
+	b := New(len(src))
+	copy(b.data, src)
+	Wipe(src)
+	return b
+}
+
+// Bytes returns the contents of the buffer, nil once destroyed. The
+// slice must not be kept after Destroy.
+func (b *Buffer) Bytes() []byte {
+	if b == nil {
+		return nil
+	}
+	return b.data
+}
+
+func (b *Buffer) Len() int {
+	return len(b.Bytes())
+}
+
+// Destroy zeroes the buffer and releases its memory. It is safe to
+// call on a nil or an already destroyed buffer.
+func (b *Buffer) Destroy() {
+	if b == nil || b.mem == nil {
+		return
+	}
+	Wipe(b.mem)
+	free(b.mem, b.mapped, b.locked)
+	b.data, b.mem, b.mapped, b.locked = nil, nil, false, false
+}
+
+// Wipe zeroes p, for the temporary copies which can't be avoided.
+func Wipe(p []byte) {
+	for i := range p {
+		p[i] = 0
+	}
+	runtime.KeepAlive(p)
+}
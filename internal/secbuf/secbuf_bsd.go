@@ -0,0 +1,7 @@
+//go:build darwin || freebsd || netbsd || openbsd
+
+package secbuf
+
+func disableDumpable() error {
+	return nil
+}
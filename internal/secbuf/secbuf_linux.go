@@ -0,0 +1,9 @@
+package secbuf
+
+import "golang.org/x/sys/unix"
+
+// disableDumpable also stops ptrace attaches from other processes of
+// the same user and hides /proc/self/mem.
+func disableDumpable() error {
+	return unix.Prctl(unix.PR_SET_DUMPABLE, 0, 0, 0, 0)
+}
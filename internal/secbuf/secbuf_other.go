@@ -0,0 +1,14 @@
+//go:build !(linux || darwin || freebsd || netbsd || openbsd)
+
+package secbuf
+
+// alloc falls back to the heap, the buffer is only wiped.
+func alloc(size int) (mem []byte, mapped, locked bool) {
+	return make([]byte, size), false, false
+}
+
+func free(mem []byte, mapped, locked bool) {}
+
+func DisableCoreDumps() error {
+	return nil
+}
@@ -0,0 +1,47 @@
+//go:build linux || darwin || freebsd || netbsd || openbsd
+
+package secbuf
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// alloc maps whole pages of its own, so locking them doesn't lock
+// unrelated heap objects and the garbage collector never copies them.
+// It falls back to the heap when the mapping fails, mapped tells
+// which one the memory came from.
+func alloc(size int) (mem []byte, mapped, locked bool) {
+	pageSize := os.Getpagesize()
+	length := (size + pageSize - 1) / pageSize * pageSize
+	if length == 0 {
+		length = pageSize
+	}
+	mem, err := unix.Mmap(-1, 0, length, unix.PROT_READ|unix.PROT_WRITE, unix.MAP_ANON|unix.MAP_PRIVATE)
+	if err != nil {
+		return make([]byte, size), false, false
+	}
+	return mem, true, unix.Mlock(mem) == nil
+}
+
+// free unmaps memory from alloc, the heap fallback is left to the
+// garbage collector.
+func free(mem []byte, mapped, locked bool) {
+	if !mapped {
+		return
+	}
+	if locked {
+		unix.Munlock(mem)
+	}
+	unix.Munmap(mem)
+}
+
+// DisableCoreDumps keeps the keys of a crashing process from ending
+// up in a core file.
+func DisableCoreDumps() error {
+	if err := unix.Setrlimit(unix.RLIMIT_CORE, &unix.Rlimit{}); err != nil {
+		return err
+	}
+	return disableDumpable()
+}
@@ -0,0 +1,85 @@
+package shred
+
+import (
+	"crypto/rand"
+	"errors"
+	"io"
+	"os"
+)
+
+// Overwriting reaches the blocks of a file only where the filesystem
+// writes in place, Warning tells where it doesn't.
+const (
+	DefaultPasses = 3
+	MaxPasses     = 35
+
+	Warning = "shredding overwrites files in place, but an SSD remaps its writes and a copy-on-write " +
+		"filesystem (btrfs, ZFS, APFS) or a snapshot keeps the old blocks, so copies may remain there; " +
+		"only encrypting the whole disk covers them"
+	NotRegularErr = "not a regular file"
+)
+
+const bufferSize = 64 * 1024
+
+// Target is what Overwrite writes to, the opened file in File.
+type Target interface {
+	io.WriteSeeker
+	Sync() error
+	Truncate(size int64) error
+}
+
+// File overwrites path with random data passes times, syncing every
+// pass to the disk, then truncates and removes it. Only regular
+// files are shredded, a symbolic link is refused rather than its
+// target overwritten.
+func File(path string, passes int) error {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return err
+	}
+	if !info.Mode().IsRegular() {
+		return &os.PathError{Op: "shred", Path: path, Err: errors.New(NotRegularErr)}
+	}
+
+	file, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if err != nil {
+		return err
+	}
+	err = Overwrite(file, info.Size(), passes)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return &os.PathError{Op: "shred", Path: path, Err: err}
+	}
+	return os.Remove(path)
+}
+
+// Overwrite writes size random bytes from the start of target passes
+// times, syncing after every pass, then truncates it and syncs again.
+func Overwrite(target Target, size int64, passes int) error {
+	buffer := make([]byte, min(size, bufferSize))
+	for pass := 0; pass < passes; pass++ {
+		if _, err := target.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+		for left := size; left > 0; {
+			n := min(left, int64(len(buffer)))
+			if _, err := rand.Read(buffer[:n]); err != nil {
+				return err
+			}
+			if _, err := target.Write(buffer[:n]); err != nil {
+				return err
+			}
+			left -= n
+		}
+		if err := target.Sync(); err != nil {
+			return err
+		}
+	}
+	// leave no length behind either
+	if err := target.Truncate(0); err != nil {
+		return err
+	}
+	return target.Sync()
+}
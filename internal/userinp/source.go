@@ -0,0 +1,128 @@
+package userinput
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"syscall"
+
+	cliarg "github.com/ShuaibKhan786/cipher-project/internal/cmdlineargs"
+	esccode "github.com/ShuaibKhan786/cipher-project/internal/escapecode"
+	secbuf "github.com/ShuaibKhan786/cipher-project/internal/secbuf"
+
+	"golang.org/x/term"
+)
+
+const (
+	PasswordEnvErr     = "environment variable is not set: "
+	PasswordCommandErr = "password command failed: "
+	StdinEchoWarning   = "WARNING: stdin is a terminal, the password will be echoed while you type it"
+)
+
+// readPasswordSource reads the password from a file, a file
+// descriptor, an environment variable, the output of a command or
+// stdin. Only the first line is used, without its line ending.
+func readPasswordSource(src cliarg.PasswordSource) (*secbuf.Buffer, error) {
+	switch src.Option {
+	case cliarg.PasswordFileOpt:
+		content, err := os.ReadFile(src.Value)
+		if err != nil {
+			return nil, err
+		}
+		return firstLine(content), nil
+	case cliarg.PasswordFDOpt:
+		fd, err := strconv.Atoi(src.Value)
+		if err != nil {
+			return nil, errors.New(cliarg.PasswordFDErr)
+		}
+		file := os.NewFile(uintptr(fd), "password-fd")
+		if file == nil {
+			return nil, errors.New(cliarg.PasswordFDErr)
+		}
+		defer file.Close()
+		return readLine(file)
+	case cliarg.PasswordEnvOpt:
+		value, ok := os.LookupEnv(src.Value)
+		if !ok {
+			return nil, errors.New(PasswordEnvErr + src.Value)
+		}
+		return secbuf.From([]byte(value)), nil
+	case cliarg.PasswordCommandOpt:
+		return runPasswordCommand(src.Value)
+	case cliarg.PasswordStdinOpt:
+		if term.IsTerminal(int(syscall.Stdin)) {
+			fmt.Fprintln(os.Stderr, esccode.Stderr(esccode.Red+StdinEchoWarning+esccode.Reset))
+		}
+		return readLine(os.Stdin)
+	}
+	return nil, errors.New(cliarg.InvalidOptionErr + src.Option)
+}
+
+// runPasswordCommand runs command through the shell, e.g. "pass show x".
+// Its stdin and stderr stay connected, so it can ask for a passphrase.
+func runPasswordCommand(command string) (*secbuf.Buffer, error) {
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.Command("cmd", "/C", command)
+	} else {
+		cmd = exec.Command("sh", "-c", command)
+	}
+	cmd.Stdin = os.Stdin
+	cmd.Stderr = os.Stderr
+
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, errors.New(PasswordCommandErr + err.Error())
+	}
+	return firstLine(output), nil
+}
+
+// readLine reads byte by byte up to the first newline, so nothing
+// after the password is consumed from a shared stdin.
+func readLine(r io.Reader) (*secbuf.Buffer, error) {
+	var line []byte
+	buffer := make([]byte, 1)
+	defer func() { secbuf.Wipe(line) }()
+	for {
+		n, err := r.Read(buffer)
+		if n == 1 {
+			if buffer[0] == '\n' {
+				break
+			}
+			line = appendByte(line, buffer[0])
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	secbuf.Wipe(buffer)
+	return secbuf.From(bytes.TrimSuffix(line, []byte("\r"))), nil
+}
+
+// appendByte grows line by hand, so the array left behind by append
+// is wiped instead of waiting for the garbage collector.
+func appendByte(line []byte, c byte) []byte {
+	if len(line) == cap(line) {
+		grown := make([]byte, len(line), 2*cap(line)+16)
+		copy(grown, line)
+		secbuf.Wipe(line)
+		line = grown
+	}
+	return append(line, c)
+}
+
+// firstLine moves the first line of content to a secure buffer and
+// wipes content.
+func firstLine(content []byte) *secbuf.Buffer {
+	line, _, _ := bytes.Cut(content, []byte("\n"))
+	defer secbuf.Wipe(content)
+	return secbuf.From(bytes.TrimSuffix(line, []byte("\r")))
+}
@@ -0,0 +1,361 @@
+package userinput
+
+import (
+	"math"
+	"strings"
+	"unicode"
+)
+
+// Scores returned by EstimateStrength, like the zxcvbn scale.
+const (
+	VeryWeakScore = 0
+	WeakScore     = 1
+	FairScore     = 2
+	GoodScore     = 3
+	StrongScore   = 4
+
+	// a word of an ordinary vocabulary, about 2000 words
+	dictionaryWordBits = 11
+)
+
+var scoreNames = []string{"very weak", "weak", "fair", "good", "strong"}
+
+// Strength is an estimation of how hard a password is to guess.
+// Entropy is in bits, after removing what the detected patterns
+// make predictable.
+type Strength struct {
+	Score    int
+	Entropy  float64
+	Warnings []string
+}
+
+func (s Strength) String() string {
+	return scoreNames[s.Score]
+}
+
+// EstimateStrength gives every character the entropy of the
+// character classes used, then lowers it for the parts matching
+// a common password, a common word, a year, a repeated character,
+// a sequence or a keyboard row.
+func EstimateStrength(password []byte) Strength {
+	runes := []rune(string(password))
+	if len(runes) == 0 {
+		return Strength{Score: VeryWeakScore, Warnings: []string{"the password is empty"}}
+	}
+
+	charBits := math.Log2(float64(poolSize(runes)))
+	bits := make([]float64, len(runes))
+	for i := range bits {
+		bits[i] = charBits
+	}
+
+	var warnings []string
+	warn := func(start, end int, patternBits float64, warning string) {
+		total := 0.0
+		for _, b := range bits[start:end] {
+			total += b
+		}
+		if total <= patternBits {
+			return
+		}
+		bits[start] = patternBits
+		for i := start + 1; i < end; i++ {
+			bits[i] = 0
+		}
+		for _, w := range warnings {
+			if w == warning {
+				return
+			}
+		}
+		warnings = append(warnings, warning)
+	}
+
+	lower := []rune(strings.ToLower(string(runes)))
+	normalized := unleet(lower)
+
+	// digits are matched as they are and as leet letters, so both
+	// "123456" and "p4ssw0rd" are found
+	for _, text := range [][]rune{lower, normalized} {
+		if isCommonPassword(text) {
+			warn(0, len(runes), math.Log2(float64(len(commonPasswords))), "it is a commonly used password")
+		}
+		for _, list := range [][]string{commonPasswords, commonWords} {
+			for _, word := range list {
+				for _, start := range indexAll(text, word) {
+					end := start + len([]rune(word))
+					warn(start, end, dictionaryWordBits+variationBits(runes[start:end], lower[start:end]), "it contains a common word")
+				}
+			}
+		}
+	}
+	for start := 0; start+4 <= len(runes); start++ {
+		if isYear(runes[start : start+4]) {
+			warn(start, start+4, math.Log2(200), "it contains a year")
+		}
+	}
+	for _, run := range repeatRuns(lower) {
+		warn(run[0], run[1], charBits+math.Log2(float64(run[1]-run[0])), "it contains repeated characters")
+	}
+	for _, run := range sequenceRuns(lower) {
+		warn(run[0], run[1], charBits+math.Log2(float64(run[1]-run[0]))+1, "it contains a sequence like abc or qwerty")
+	}
+
+	entropy := 0.0
+	for _, b := range bits {
+		entropy += b
+	}
+
+	// "abcXYZabcXYZ" is no harder than "abcXYZ" typed twice
+	if unit, count := repeatedUnit(runes); count > 1 {
+		unitStrength := EstimateStrength([]byte(string(unit)))
+		if e := unitStrength.Entropy + math.Log2(float64(count)); e < entropy {
+			entropy = e
+			warnings = append(warnings, "it repeats the same characters several times")
+		}
+	}
+
+	if len(runes) < 8 {
+		warnings = append(warnings, "it is shorter than 8 characters")
+	}
+
+	return Strength{
+		Score:    score(entropy),
+		Entropy:  entropy,
+		Warnings: warnings,
+	}
+}
+
+func score(entropy float64) int {
+	switch {
+	case entropy < 28:
+		return VeryWeakScore
+	case entropy < 40:
+		return WeakScore
+	case entropy < 55:
+		return FairScore
+	case entropy < 70:
+		return GoodScore
+	}
+	return StrongScore
+}
+
+func poolSize(runes []rune) int {
+	var lower, upper, digit, symbol, other bool
+	for _, r := range runes {
+		switch {
+		case r >= 'a' && r <= 'z':
+			lower = true
+		case r >= 'A' && r <= 'Z':
+			upper = true
+		case r >= '0' && r <= '9':
+			digit = true
+		case r < unicode.MaxASCII && unicode.IsPrint(r):
+			symbol = true
+		default:
+			other = true
+		}
+	}
+
+	size := 0
+	for _, class := range []struct {
+		used bool
+		size int
+	}{{lower, 26}, {upper, 26}, {digit, 10}, {symbol, 33}, {other, 100}} {
+		if class.used {
+			size += class.size
+		}
+	}
+	return size
+}
+
+var leet = map[rune]rune{
+	'4': 'a', '@': 'a', '8': 'b', '3': 'e', '6': 'g', '1': 'i', '!': 'i',
+	'0': 'o', '$': 's', '5': 's', '7': 't', '+': 't', '2': 'z',
+}
+
+func unleet(runes []rune) []rune {
+	normalized := make([]rune, len(runes))
+	for i, r := range runes {
+		if n, ok := leet[r]; ok {
+			r = n
+		}
+		normalized[i] = r
+	}
+	return normalized
+}
+
+// variationBits is the little extra a guesser pays for capitals and
+// leet substitutions inside a word.
+func variationBits(original, lower []rune) float64 {
+	extra := 0.0
+	for i, r := range original {
+		if r != lower[i] {
+			extra++
+			break
+		}
+	}
+	for _, r := range lower {
+		if _, ok := leet[r]; ok {
+			extra++
+			break
+		}
+	}
+	return extra
+}
+
+func isCommonPassword(runes []rune) bool {
+	for _, password := range commonPasswords {
+		if string(runes) == password {
+			return true
+		}
+	}
+	return false
+}
+
+// indexAll returns the rune index of every occurrence of word, words
+// shorter than 4 characters are too likely to match by chance.
+func indexAll(runes []rune, word string) []int {
+	var found []int
+	text := string(runes)
+	if len(word) < 4 {
+		return nil
+	}
+	for offset := 0; ; {
+		i := strings.Index(text[offset:], word)
+		if i < 0 {
+			return found
+		}
+		found = append(found, len([]rune(text[:offset+i])))
+		offset += i + 1
+	}
+}
+
+func isYear(runes []rune) bool {
+	s := string(runes)
+	if !(strings.HasPrefix(s, "19") || strings.HasPrefix(s, "20")) {
+		return false
+	}
+	return unicode.IsDigit(runes[2]) && unicode.IsDigit(runes[3])
+}
+
+// repeatRuns returns [start, end) of every run of 3 or more identical
+// characters.
+func repeatRuns(runes []rune) [][2]int {
+	var runs [][2]int
+	for start := 0; start < len(runes); {
+		end := start + 1
+		for end < len(runes) && runes[end] == runes[start] {
+			end++
+		}
+		if end-start >= 3 {
+			runs = append(runs, [2]int{start, end})
+		}
+		start = end
+	}
+	return runs
+}
+
+var keyboardRows = []string{"1234567890", "qwertyuiop", "asdfghjkl", "zxcvbnm", "qazwsxedc"}
+
+// sequenceRuns returns [start, end) of every run of 3 or more
+// characters going up or down by one, or following a keyboard row.
+func sequenceRuns(runes []rune) [][2]int {
+	var runs [][2]int
+	for start := 0; start < len(runes)-2; {
+		end := start + 1
+		step := runes[end] - runes[start]
+		if step == 1 || step == -1 {
+			for end < len(runes) && runes[end]-runes[end-1] == step {
+				end++
+			}
+		}
+		if end-start >= 3 {
+			runs = append(runs, [2]int{start, end})
+			start = end
+			continue
+		}
+		start++
+	}
+
+	text := string(runes)
+	for _, row := range keyboardRows {
+		for _, r := range []string{row, reverse(row)} {
+			for length := len(r); length >= 4; length-- {
+				for i := 0; i+length <= len(r); i++ {
+					if at := strings.Index(text, r[i:i+length]); at >= 0 {
+						start := len([]rune(text[:at]))
+						runs = append(runs, [2]int{start, start + length})
+					}
+				}
+			}
+		}
+	}
+	return runs
+}
+
+func repeatedUnit(runes []rune) ([]rune, int) {
+	for size := 1; size <= len(runes)/2; size++ {
+		if len(runes)%size != 0 {
+			continue
+		}
+		unit := string(runes[:size])
+		if strings.Repeat(unit, len(runes)/size) == string(runes) {
+			return runes[:size], len(runes) / size
+		}
+	}
+	return nil, 0
+}
+
+func reverse(s string) string {
+	runes := []rune(s)
+	for i, j := 0, len(runes)-1; i < j; i, j = i+1, j-1 {
+		runes[i], runes[j] = runes[j], runes[i]
+	}
+	return string(runes)
+}
+
+var commonPasswords = []string{
+	"123456", "password", "12345678", "qwerty", "123456789", "12345", "1234",
+	"111111", "1234567", "dragon", "123123", "baseball", "abc123", "football",
+	"monkey", "letmein", "696969", "shadow", "master", "666666", "qwertyuiop",
+	"123321", "mustang", "1234567890", "michael", "654321", "superman",
+	"1qaz2wsx", "7777777", "121212", "000000", "qazwsx", "123qwe", "killer",
+	"trustno1", "jordan", "jennifer", "zxcvbnm", "asdfgh", "hunter", "buster",
+	"soccer", "harley", "batman", "andrew", "tigger", "sunshine", "iloveyou",
+	"2000", "charlie", "robert", "thomas", "hockey", "ranger", "daniel",
+	"starwars", "klaster", "112233", "george", "computer", "michelle",
+	"jessica", "pepper", "1111", "zxcvbn", "555555", "11111111", "131313",
+	"freedom", "777777", "pass", "maggie", "159753", "aaaaaa", "ginger",
+	"princess", "joshua", "cheese", "amanda", "summer", "love", "ashley",
+	"nicole", "chelsea", "biteme", "matthew", "access", "yankees", "987654321",
+	"dallas", "austin", "thunder", "taylor", "matrix", "mobilemail", "mom",
+	"monitor", "monitoring", "montana", "moon", "moscow", "welcome", "admin",
+	"login", "passw0rd", "password1", "qwerty123", "secret", "changeme",
+	"whatever", "default", "root", "toor", "administrator", "guest",
+}
+
+var commonWords = []string{
+	"love", "angel", "baby", "girl", "life", "home", "happy", "family",
+	"friend", "forever", "heart", "money", "power", "magic", "music", "game",
+	"games", "hello", "world", "apple", "orange", "banana", "cherry", "lemon",
+	"tiger", "lion", "eagle", "falcon", "wolf", "bear", "horse", "puppy",
+	"kitty", "fish", "bird", "dragon", "monkey", "star", "stars", "moon",
+	"summer", "winter", "spring", "autumn", "rain", "snow", "fire", "water",
+	"earth", "wind", "storm", "thunder", "light", "dark", "black", "white",
+	"blue", "green", "yellow", "purple", "pink", "silver", "gold", "diamond",
+	"king", "queen", "prince", "princess", "master", "lord", "jesus",
+	"christ", "heaven", "hell", "devil", "secret", "private", "admin", "user",
+	"login", "pass", "word", "test", "guest", "welcome", "sunday", "monday",
+	"friday", "january", "february", "march", "april", "june", "july",
+	"august", "september", "october", "november", "december", "football",
+	"soccer", "baseball", "hockey", "basketball", "tennis", "golf", "chelsea",
+	"arsenal", "liverpool", "united", "city", "america", "london", "paris",
+	"berlin", "india", "china", "pakistan", "beach", "ocean", "island",
+	"mountain", "school", "college", "teacher", "doctor", "computer",
+	"internet", "phone", "mobile", "office", "company", "business", "coffee",
+	"pizza", "chocolate", "cookie", "sugar", "honey", "sweet", "cool",
+	"super", "hunter", "killer", "ninja", "pirate", "soldier", "warrior",
+	"shadow", "ghost", "zombie", "matrix", "batman", "superman", "spider",
+	"starwars", "pokemon", "mario", "naruto", "qwerty", "letmein", "trust",
+	"freedom",
+}
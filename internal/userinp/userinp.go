@@ -1,51 +1,206 @@
 package userinput
 
 import (
+	"bufio"
+	"bytes"
 	"errors"
 	"fmt"
+	"os"
+	"strings"
 	"syscall"
 
 	cliarg "github.com/ShuaibKhan786/cipher-project/internal/cmdlineargs"
-    esccode "github.com/ShuaibKhan786/cipher-project/internal/escapecode" 
+	esccode "github.com/ShuaibKhan786/cipher-project/internal/escapecode"
+	secbuf "github.com/ShuaibKhan786/cipher-project/internal/secbuf"
 
 	"golang.org/x/term"
 )
 
-func ReadPassword(operation string) ([]byte, error) {
-    switch operation {
-    case cliarg.EncryptionOp:
+const (
+	EmptyPasswordErr    = "the password can't be empty"
+	WeakPasswordErr     = "the password is too weak"
+	PasswordMismatchErr = "the passwords don't match, try again"
+	PasswordAttemptsErr = "too many attempts"
+
+	maxPasswordAttempts = 3
+	maxListedFiles      = 10
+)
+
+// ReadPassword reads the password from src, or from the terminal
+// with echo off when src is the zero value. A password for encryption
+// must reach minScore, and is typed twice on the terminal. The caller
+// destroys the returned buffer.
+func ReadPassword(operation string, src cliarg.PasswordSource, minScore int) (*secbuf.Buffer, error) {
+	switch operation {
+	case cliarg.EncryptionOp, cliarg.DecryptionOp, cliarg.VerifyOp, cliarg.RekeyOp, cliarg.MigrateOp, cliarg.AddSlotOp, cliarg.RemoveSlotOp, cliarg.UnpackOp, cliarg.VaultOp:
+	default:
+		return nil, errors.New(cliarg.InvalidOpErr)
+	}
+	if src.Option != "" {
+		pw, err := readPasswordSource(src)
+		if err != nil {
+			return nil, err
+		}
+		if operation == cliarg.EncryptionOp {
+			if err := checkStrength(pw.Bytes(), minScore); err != nil {
+				pw.Destroy()
+				return nil, err
+			}
+		}
+		return pw, nil
+	}
+
+	if operation == cliarg.EncryptionOp {
 		fmt.Print(esccode.Red)
-        fmt.Println("WARNING: Please remember your password!",esccode.Reset,esccode.Green)
-        fmt.Println("Once the password is lost, decryption will not be possible.")
-        fmt.Println("It is highly recommended to use a strong and memorable password.")
-        fmt.Println(esccode.Reset)
-    case cliarg.DecryptionOp:
-    default:
-        return nil, errors.New(cliarg.InvalidOpErr)
-    }
-	fmt.Printf(esccode.Yellow + "Password: " + esccode.Reset)
-
-    pw, err := term.ReadPassword(int(syscall.Stdin))
-    if err != nil {
-        return nil, err
-    }
-    fmt.Println()
-    return pw, nil
+		fmt.Println("WARNING: Please remember your password!", esccode.Reset, esccode.Green)
+		fmt.Println("Once the password is lost, decryption will not be possible.")
+		fmt.Println("It is highly recommended to use a strong and memorable password.")
+		fmt.Println(esccode.Reset)
+		return readConfirmedPassword(minScore)
+	}
+	return promptPassword("Password: ")
 }
 
-func DeleteAllfilesChoice() bool {
-    var choice string
-    fmt.Println(esccode.Yellow)
-    fmt.Println("Do you want to remove all the files? (y/n)",esccode.Reset)
-    fmt.Scanf("%s", &choice) 
-    switch choice {
-    case "y", "Y":
-        return true
-    case "n", "N":
-        return false
-    default:
-        fmt.Println(esccode.Red,"Invalid choice. Assuming 'no'.",esccode.Reset)
-        return false
-    }
+// ReadNewPassword asks for the password replacing the current one
+// during a password change.
+func ReadNewPassword(minScore int) (*secbuf.Buffer, error) {
+	fmt.Println(esccode.Green + "Now enter the new password" + esccode.Reset)
+	return ReadPassword(cliarg.EncryptionOp, cliarg.PasswordSource{}, minScore)
+}
+
+// readConfirmedPassword asks for the password until it is strong
+// enough and typed the same twice, a typo would make the files
+// impossible to decrypt.
+func readConfirmedPassword(minScore int) (*secbuf.Buffer, error) {
+	for attempt := 0; attempt < maxPasswordAttempts; attempt++ {
+		pw, err := promptPassword("Password: ")
+		if err != nil {
+			return nil, err
+		}
+		if err := checkStrength(pw.Bytes(), minScore); err != nil {
+			pw.Destroy()
+			fmt.Println(esccode.Red + err.Error() + esccode.Reset)
+			continue
+		}
+
+		confirm, err := promptPassword("Confirm password: ")
+		if err != nil {
+			pw.Destroy()
+			return nil, err
+		}
+		same := bytes.Equal(pw.Bytes(), confirm.Bytes())
+		confirm.Destroy()
+		if !same {
+			pw.Destroy()
+			fmt.Println(esccode.Red + PasswordMismatchErr + esccode.Reset)
+			continue
+		}
+		return pw, nil
+	}
+	return nil, errors.New(PasswordAttemptsErr)
+}
+
+// checkStrength refuses empty passwords and passwords scoring below
+// minScore, and warns about the ones that are only passable.
+func checkStrength(pw []byte, minScore int) error {
+	if len(pw) == 0 {
+		return errors.New(EmptyPasswordErr)
+	}
+	strength := EstimateStrength(pw)
+	reasons := strings.Join(strength.Warnings, ", ")
+	if strength.Score < minScore {
+		return fmt.Errorf("%s (%s, minimum is %s): %s", WeakPasswordErr, strength, scoreNames[minScore], reasons)
+	}
+	if strength.Score < GoodScore {
+		// on stderr, stdout may carry JSON events
+		fmt.Fprintln(os.Stderr, esccode.Stderr(esccode.Yellow+"WARNING: the password is only "+strength.String()+": "+reasons+esccode.Reset))
+	}
+	return nil
+}
+
+func promptPassword(prompt string) (*secbuf.Buffer, error) {
+	fmt.Printf(esccode.Yellow + prompt + esccode.Reset)
+
+	pw, err := term.ReadPassword(int(syscall.Stdin))
+	if err != nil {
+		return nil, err
+	}
+	fmt.Println()
+	return secbuf.From(pw), nil
+}
+
+// ReadGroupPassword asks for the password of files which the
+// passwords given so far didn't open. An empty password skips them.
+func ReadGroupPassword(fileNames []string) (*secbuf.Buffer, error) {
+	fmt.Println(esccode.Yellow + "Another password is needed to open:" + esccode.Reset)
+	for i, filename := range fileNames {
+		if i == maxListedFiles {
+			fmt.Printf("\t... and %d more\n", len(fileNames)-i)
+			break
+		}
+		fmt.Println("\t" + filename)
+	}
+	return promptPassword("Password (empty to skip them): ")
+}
+
+// UsePassphraseChoice shows a generated passphrase, once, and asks
+// whether to encrypt with it.
+func UsePassphraseChoice(passphrase string, entropy float64) bool {
+	var choice string
+	fmt.Println(esccode.Green + "Generated passphrase, write it down now, it will not be shown again:" + esccode.Reset)
+	fmt.Println()
+	fmt.Println("\t" + passphrase)
+	fmt.Println()
+	fmt.Printf("%.0f bits of entropy\n", entropy)
+	fmt.Println(esccode.Yellow)
+	fmt.Println("Do you want to encrypt with this passphrase? (y/n)", esccode.Reset)
+	fmt.Scanf("%s", &choice)
+	switch choice {
+	case "y", "Y":
+		return true
+	default:
+		fmt.Println(esccode.Yellow, "Enter your own password instead.", esccode.Reset)
+		return false
+	}
+}
+
+// ReadSlotNumber asks which key slot to remove, returns -1 when
+// the answer is not a number.
+func ReadSlotNumber() int {
+	var index int
+	fmt.Println(esccode.Yellow)
+	fmt.Println("Which slot do you want to remove?", esccode.Reset)
+	if _, err := fmt.Scanf("%d", &index); err != nil {
+		return -1
+	}
+	return index
+}
+
+// ReadKeyfilePath asks for the keyfile the given files were
+// encrypted with.
+func ReadKeyfilePath(fileNames []string) string {
+	fmt.Println(esccode.Yellow + "A keyfile is required to open:" + esccode.Reset)
+	for _, filename := range fileNames {
+		fmt.Println("\t" + filename)
+	}
+	fmt.Print(esccode.Yellow + "Keyfile path: " + esccode.Reset)
+
+	path, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	return strings.TrimSpace(path)
 }
 
+func DeleteAllfilesChoice() bool {
+	var choice string
+	fmt.Println(esccode.Yellow)
+	fmt.Println("Do you want to remove all the files? (y/n)", esccode.Reset)
+	fmt.Scanf("%s", &choice)
+	switch choice {
+	case "y", "Y":
+		return true
+	case "n", "N":
+		return false
+	default:
+		fmt.Println(esccode.Red, "Invalid choice. Assuming 'no'.", esccode.Reset)
+		return false
+	}
+}
@@ -0,0 +1,465 @@
+package vault
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	cipher "github.com/ShuaibKhan786/cipher-project/internal/cipher"
+	header "github.com/ShuaibKhan786/cipher-project/internal/header"
+	secbuf "github.com/ShuaibKhan786/cipher-project/internal/secbuf"
+)
+
+// On-disk layout of a vault:
+//
+//	header | payload streams | index stream | trailer
+//
+// The header is the one of any encrypted file, with FlagVault set.
+// Every payload and the index are sealed with the data key as
+// separate streams, each one with its own random nonce. The trailer
+// is the last thing in the file and tells where the index is, so
+// listing reads the index only. Adding appends the new payloads and
+// a new index, the old index is left behind until compaction.
+//
+//	trailer: magic(4) | index offset(8) | index length(8) | previous end(8)
+//
+// The nonce of the current index is the header nonce, rewritten by
+// every commit, so an older index left in the file doesn't open
+// once the vault is cut back to it. Previous end is where the last
+// commit ended, a commit interrupted before the header was rewritten
+// is dropped when the vault is opened.
+const (
+	TrailerMagic = "EEVI"
+	trailerSize  = len(TrailerMagic) + 8 + 8 + 8
+
+	NotVaultErr       = "not an EncryptEase vault"
+	EntryExistsErr    = "the vault already has an entry named: "
+	EntryNotFoundErr  = "no entry named: "
+	CorruptedIndexErr = "the vault index is damaged"
+)
+
+// Entry describes one file stored in the vault, where its payload
+// stream starts and ends and the nonce it was sealed with.
+type Entry struct {
+	Name    string
+	Size    int64
+	Mode    fs.FileMode
+	ModTime time.Time
+	Offset  int64
+	Length  int64
+	Nonce   []byte
+}
+
+type Vault struct {
+	file  *os.File
+	hdr   *header.Header
+	key   *secbuf.Buffer
+	index []Entry
+	// indexLength is the sealed size of the committed index
+	indexLength int64
+	// size is the end of the last committed trailer, anything written
+	// after it is dropped if the commit fails
+	size  int64
+	dirty bool
+}
+
+// Create writes a new empty vault, hdr must wrap key.
+func Create(name string, hdr *header.Header, key *secbuf.Buffer) (*Vault, error) {
+	file, err := os.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return nil, err
+	}
+	hdr.Flags |= header.FlagVault
+	v := &Vault{file: file, hdr: hdr, key: key}
+	if err := hdr.Write(file); err != nil {
+		v.abandon(name)
+		return nil, err
+	}
+	v.size = hdr.Size()
+	v.dirty = true
+	if err := v.Commit(); err != nil {
+		v.abandon(name)
+		return nil, err
+	}
+	return v, nil
+}
+
+// Open reads the index of the vault name, with hdr and key as
+// returned when its header was unlocked.
+func Open(name string, hdr *header.Header, key *secbuf.Buffer) (*Vault, error) {
+	if hdr.Flags&header.FlagVault == 0 {
+		return nil, errors.New(NotVaultErr)
+	}
+	file, err := os.OpenFile(name, os.O_RDWR, 0)
+	if err != nil {
+		return nil, err
+	}
+	v := &Vault{file: file, hdr: hdr, key: key}
+	if err := v.readIndex(); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return v, nil
+}
+
+// Entries are sorted by name.
+func (v *Vault) Entries() []Entry {
+	return v.index
+}
+
+// Garbage is the number of bytes left behind by old indexes and
+// removed entries, which compaction would give back.
+func (v *Vault) Garbage() int64 {
+	used := v.hdr.Size() + v.indexLength + int64(trailerSize)
+	for _, entry := range v.index {
+		used += entry.Length
+	}
+	return v.size - used
+}
+
+// Add seals the content of r as a new entry. Nothing is visible in
+// the vault before Commit.
+func (v *Vault) Add(name string, r io.Reader, info fs.FileInfo) error {
+	if _, ok := v.find(name); ok {
+		return errors.New(EntryExistsErr + name)
+	}
+	offset, err := v.file.Seek(0, io.SeekEnd)
+	if err != nil {
+		return err
+	}
+	nonce, length, err := v.writeStream(r)
+	if err != nil {
+		return err
+	}
+	v.index = append(v.index, Entry{
+		Name:    name,
+		Size:    info.Size(),
+		Mode:    info.Mode().Perm(),
+		ModTime: info.ModTime(),
+		Offset:  offset,
+		Length:  length,
+		Nonce:   nonce,
+	})
+	sort.Slice(v.index, func(i, j int) bool { return v.index[i].Name < v.index[j].Name })
+	v.dirty = true
+	return nil
+}
+
+// Extract decrypts the entry called name into w.
+func (v *Vault) Extract(name string, w io.Writer) error {
+	i, ok := v.find(name)
+	if !ok {
+		return errors.New(EntryNotFoundErr + name)
+	}
+	entry := v.index[i]
+	r, err := cipher.NewSectionReader(io.NewSectionReader(v.file, entry.Offset, entry.Length), v.key, entry.Nonce)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(w, r)
+	return err
+}
+
+// Remove drops the entry from the index, its payload stays in the
+// file until Compact.
+func (v *Vault) Remove(name string) error {
+	i, ok := v.find(name)
+	if !ok {
+		return errors.New(EntryNotFoundErr + name)
+	}
+	v.index = append(v.index[:i], v.index[i+1:]...)
+	v.dirty = true
+	return nil
+}
+
+// Commit writes the index and the trailer after everything added,
+// syncs the file and then puts the index nonce in the header. On
+// failure the vault is cut back to its last commit, so it still
+// opens with the previous index.
+func (v *Vault) Commit() error {
+	if !v.dirty {
+		return nil
+	}
+	nonce, err := newNonce()
+	if err != nil {
+		v.Rollback()
+		return err
+	}
+	offset, length, err := writeIndex(v.file, v.index, v.key, nonce, v.size)
+	if err == nil {
+		err = v.file.Sync()
+	}
+	if err == nil {
+		err = v.setNonce(nonce)
+	}
+	if err != nil {
+		v.Rollback()
+		return err
+	}
+	v.size = offset + length + int64(trailerSize)
+	v.indexLength = length
+	v.dirty = false
+	return nil
+}
+
+// Rollback drops everything written since the last commit, and puts
+// back the header nonce of its index.
+func (v *Vault) Rollback() error {
+	v.dirty = false
+	if v.size == 0 {
+		return nil
+	}
+	if err := v.file.Truncate(v.size); err != nil {
+		return err
+	}
+	if err := v.hdr.WriteNonce(v.file); err != nil {
+		return err
+	}
+	return v.file.Sync()
+}
+
+// Compact rewrites the vault with the live entries only. The new
+// vault is written next to the old one and renamed over it, the
+// sealed payloads are copied as they are.
+func (v *Vault) Compact() error {
+	name := v.file.Name()
+	tmp, err := os.CreateTemp(filepath.Dir(name), filepath.Base(name)+".compact-*")
+	if err != nil {
+		return err
+	}
+	nonce, err := newNonce()
+	if err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	live, length, err := v.copyLive(tmp, nonce)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		if info, statErr := v.file.Stat(); statErr == nil {
+			os.Chmod(tmp.Name(), info.Mode().Perm())
+		}
+		err = os.Rename(tmp.Name(), name)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	v.file.Close()
+	file, err := os.OpenFile(name, os.O_RDWR, 0)
+	if err != nil {
+		return err
+	}
+	v.file = file
+	v.hdr.Nonce = nonce
+	v.index, v.indexLength, v.dirty = live, length, false
+	v.size, err = file.Seek(0, io.SeekEnd)
+	return err
+}
+
+func (v *Vault) Close() error {
+	if v.dirty {
+		v.Rollback()
+	}
+	return v.file.Close()
+}
+
+// copyLive writes a vault holding the live entries only to w, its
+// index sealed with nonce, and returns the index.
+func (v *Vault) copyLive(w *os.File, nonce []byte) ([]Entry, int64, error) {
+	hdr := *v.hdr
+	hdr.Nonce = nonce
+	if err := hdr.Write(w); err != nil {
+		return nil, 0, err
+	}
+	offset := v.hdr.Size()
+	live := make([]Entry, len(v.index))
+	for i, entry := range v.index {
+		if _, err := io.Copy(w, io.NewSectionReader(v.file, entry.Offset, entry.Length)); err != nil {
+			return nil, 0, err
+		}
+		entry.Offset = offset
+		live[i] = entry
+		offset += entry.Length
+	}
+	_, length, err := writeIndex(w, live, v.key, nonce, 0)
+	return live, length, err
+}
+
+// writeStream appends r sealed with a new nonce at the current
+// position and returns the nonce and the sealed length.
+func (v *Vault) writeStream(r io.Reader) ([]byte, int64, error) {
+	nonce, err := newNonce()
+	if err != nil {
+		return nil, 0, err
+	}
+	counter := &countingWriter{w: v.file}
+	sw, err := cipher.NewStreamWriter(counter, v.key, nonce)
+	if err != nil {
+		return nil, 0, err
+	}
+	if _, err := io.Copy(sw, r); err != nil {
+		return nil, 0, err
+	}
+	if err := sw.Close(); err != nil {
+		return nil, 0, err
+	}
+	return nonce, counter.n, nil
+}
+
+// setNonce makes nonce, the one of the index just written, the
+// header nonce.
+func (v *Vault) setNonce(nonce []byte) error {
+	old := v.hdr.Nonce
+	v.hdr.Nonce = nonce
+	err := v.hdr.WriteNonce(v.file)
+	if err == nil {
+		err = v.file.Sync()
+	}
+	if err != nil {
+		v.hdr.Nonce = old
+	}
+	return err
+}
+
+// writeIndex appends the index sealed with nonce and the trailer
+// pointing to it, and returns where the sealed index starts and its
+// length. previous is the end of the last commit.
+func writeIndex(w io.WriteSeeker, index []Entry, key *secbuf.Buffer, nonce []byte, previous int64) (int64, int64, error) {
+	content, err := json.Marshal(index)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer secbuf.Wipe(content)
+	offset, err := w.Seek(0, io.SeekEnd)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	counter := &countingWriter{w: w}
+	sw, err := cipher.NewStreamWriter(counter, key, nonce)
+	if err != nil {
+		return 0, 0, err
+	}
+	if _, err := sw.Write(content); err != nil {
+		return 0, 0, err
+	}
+	if err := sw.Close(); err != nil {
+		return 0, 0, err
+	}
+
+	trailer := make([]byte, 0, trailerSize)
+	trailer = append(trailer, TrailerMagic...)
+	trailer = binary.BigEndian.AppendUint64(trailer, uint64(offset))
+	trailer = binary.BigEndian.AppendUint64(trailer, uint64(counter.n))
+	trailer = binary.BigEndian.AppendUint64(trailer, uint64(previous))
+	_, err = w.Write(trailer)
+	return offset, counter.n, err
+}
+
+func (v *Vault) readIndex() error {
+	size, err := v.file.Seek(0, io.SeekEnd)
+	if err != nil {
+		return err
+	}
+	previous, err := v.readIndexAt(size)
+	if err == nil {
+		return nil
+	}
+	// a commit interrupted before the header nonce was rewritten, the
+	// index of the commit before it is still the current one
+	if previous < v.hdr.Size()+int64(trailerSize) || previous >= size {
+		return err
+	}
+	if _, prevErr := v.readIndexAt(previous); prevErr != nil {
+		return err
+	}
+	return v.file.Truncate(previous)
+}
+
+// readIndexAt reads the index of the trailer ending at end, and
+// returns the previous end found in the trailer.
+func (v *Vault) readIndexAt(end int64) (int64, error) {
+	if end < v.hdr.Size()+int64(trailerSize) {
+		return 0, errors.New(CorruptedIndexErr)
+	}
+	trailer := make([]byte, trailerSize)
+	if _, err := v.file.ReadAt(trailer, end-int64(trailerSize)); err != nil {
+		return 0, err
+	}
+	if string(trailer[:len(TrailerMagic)]) != TrailerMagic {
+		return 0, errors.New(CorruptedIndexErr)
+	}
+	off := len(TrailerMagic)
+	offset := int64(binary.BigEndian.Uint64(trailer[off:]))
+	length := int64(binary.BigEndian.Uint64(trailer[off+8:]))
+	previous := int64(binary.BigEndian.Uint64(trailer[off+16:]))
+	if offset < v.hdr.Size() || length < 0 || offset+length > end-int64(trailerSize) {
+		return 0, errors.New(CorruptedIndexErr)
+	}
+
+	r, err := cipher.NewSectionReader(io.NewSectionReader(v.file, offset, length), v.key, v.hdr.Nonce)
+	if err != nil {
+		return previous, err
+	}
+	var content bytes.Buffer
+	if _, err := io.Copy(&content, r); err != nil {
+		return previous, err
+	}
+	defer secbuf.Wipe(content.Bytes())
+	var index []Entry
+	if err := json.Unmarshal(content.Bytes(), &index); err != nil {
+		return previous, errors.New(CorruptedIndexErr)
+	}
+	v.index = index
+	v.size = end
+	v.indexLength = length
+	return previous, nil
+}
+
+func newNonce() ([]byte, error) {
+	nonce := make([]byte, header.NonceSize)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return nonce, nil
+}
+
+func (v *Vault) find(name string) (int, bool) {
+	for i := range v.index {
+		if v.index[i].Name == name {
+			return i, true
+		}
+	}
+	return -1, false
+}
+
+func (v *Vault) abandon(name string) {
+	v.file.Close()
+	os.Remove(name)
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
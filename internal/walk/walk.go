@@ -0,0 +1,191 @@
+package walk
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Policies for what a walk meets besides directories and regular
+// files. Roots given on the command line are always taken, the
+// policies only apply to what is found inside directories.
+const (
+	SymlinkSkip   = "skip"
+	SymlinkFollow = "follow"
+	SymlinkError  = "error"
+
+	SpecialSkip  = "skip"
+	SpecialError = "error"
+
+	HiddenInclude = "include"
+	HiddenSkip    = "skip"
+
+	BadPatternErr  = "invalid pattern: "
+	SymlinkErr     = "symbolic link found: "
+	SymlinkLoopErr = "symbolic link loop: "
+	SpecialFileErr = "special file found: "
+)
+
+type Policy struct {
+	Symlinks string
+	Special  string
+	Hidden   string
+	// SkipDir is not entered, so an output root inside the walked
+	// tree doesn't feed the walk with its own results.
+	SkipDir string
+	// Include and Exclude are glob patterns, matched against the
+	// file name, or against the last path elements when they have a
+	// slash, so "sub/*.txt" matches the text files of any sub.
+	// A file is taken when it matches an include pattern, or there
+	// are none, and no exclude pattern. Excluded directories are not
+	// entered.
+	Include []string
+	Exclude []string
+}
+
+// ValidPatterns returns the first malformed pattern of p.
+func (p Policy) ValidPatterns() error {
+	for _, pattern := range append(append([]string{}, p.Include...), p.Exclude...) {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return errors.New(BadPatternErr + pattern)
+		}
+	}
+	return nil
+}
+
+// Wanted reports whether the include and exclude patterns take the
+// file at path.
+func (p Policy) Wanted(path string) bool {
+	if matchAny(p.Exclude, path) {
+		return false
+	}
+	return len(p.Include) == 0 || matchAny(p.Include, path)
+}
+
+func matchAny(patterns []string, path string) bool {
+	elems := strings.Split(filepath.ToSlash(filepath.Clean(path)), "/")
+	for _, pattern := range patterns {
+		n := strings.Count(pattern, "/") + 1
+		if n > len(elems) {
+			continue
+		}
+		subject := strings.Join(elems[len(elems)-n:], "/")
+		if ok, _ := filepath.Match(pattern, subject); ok {
+			return true
+		}
+	}
+	return false
+}
+
+var DefaultPolicy = Policy{
+	Symlinks: SymlinkSkip,
+	Special:  SpecialSkip,
+	Hidden:   HiddenInclude,
+}
+
+// Entry is a file found by the walk, Rel is its path relative to
+// the parent of the root it was found under, so a mirrored tree
+// keeps the name of the root directory.
+type Entry struct {
+	Path string
+	Rel  string
+}
+
+// Files expands every directory of roots into the regular files it
+// contains, in lexical order. keep chooses the files wanted by name,
+// for example only the ones ending with .enc for decryption.
+func Files(roots []string, p Policy, keep func(name string) bool) ([]Entry, error) {
+	w := &walker{policy: p, keep: keep}
+	if p.SkipDir != "" {
+		w.skip, _ = os.Stat(p.SkipDir)
+	}
+
+	for _, root := range roots {
+		info, err := os.Stat(root)
+		if err != nil {
+			return nil, err
+		}
+		base := filepath.Base(filepath.Clean(root))
+		if !info.IsDir() {
+			if p.Wanted(root) {
+				w.entries = append(w.entries, Entry{Path: root, Rel: base})
+			}
+			continue
+		}
+		if err := w.dir(root, base, []fs.FileInfo{info}); err != nil {
+			return nil, err
+		}
+	}
+	return w.entries, nil
+}
+
+type walker struct {
+	policy  Policy
+	keep    func(name string) bool
+	skip    fs.FileInfo
+	entries []Entry
+}
+
+// dir walks one directory, parents are the directories above it so
+// a followed symbolic link pointing back up is caught.
+func (w *walker) dir(path, rel string, parents []fs.FileInfo) error {
+	children, err := os.ReadDir(path)
+	if err != nil {
+		return err
+	}
+
+	for _, child := range children {
+		name := child.Name()
+		childPath := filepath.Join(path, name)
+		childRel := filepath.Join(rel, name)
+		if w.policy.Hidden == HiddenSkip && strings.HasPrefix(name, ".") {
+			continue
+		}
+
+		info, err := os.Lstat(childPath)
+		if err != nil {
+			return err
+		}
+		if info.Mode()&fs.ModeSymlink != 0 {
+			switch w.policy.Symlinks {
+			case SymlinkError:
+				return errors.New(SymlinkErr + childPath)
+			case SymlinkFollow:
+				if info, err = os.Stat(childPath); err != nil {
+					return err
+				}
+			default:
+				continue
+			}
+		}
+
+		switch {
+		case info.IsDir():
+			if w.skip != nil && os.SameFile(info, w.skip) {
+				continue
+			}
+			if matchAny(w.policy.Exclude, childPath) {
+				continue
+			}
+			for _, parent := range parents {
+				if os.SameFile(info, parent) {
+					return errors.New(SymlinkLoopErr + childPath)
+				}
+			}
+			if err := w.dir(childPath, childRel, append(parents, info)); err != nil {
+				return err
+			}
+		case info.Mode().IsRegular():
+			if (w.keep == nil || w.keep(name)) && w.policy.Wanted(childPath) {
+				w.entries = append(w.entries, Entry{Path: childPath, Rel: childRel})
+			}
+		default:
+			if w.policy.Special == SpecialError {
+				return errors.New(SpecialFileErr + childPath)
+			}
+		}
+	}
+	return nil
+}
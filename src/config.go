@@ -0,0 +1,29 @@
+package main
+
+import (
+	"fmt"
+
+	cliarg "github.com/ShuaibKhan786/cipher-project/internal/cmdlineargs"
+	config "github.com/ShuaibKhan786/cipher-project/internal/config"
+	esccode "github.com/ShuaibKhan786/cipher-project/internal/escapecode"
+)
+
+// showConfig prints the config files read, then every setting with
+// its effective value and where that value came from.
+func showConfig(md *cliarg.ArgsMetaData) {
+	if user, err := config.UserFile(); err == nil {
+		fmt.Printf("%suser file:    %s%s\n", esccode.Cyan, user, esccode.Reset)
+	}
+	fmt.Printf("%sproject file: %s%s\n", esccode.Cyan, config.ProjectFile, esccode.Reset)
+	if len(md.ConfigFiles) == 0 {
+		fmt.Println("no config file found, every setting is a default")
+	}
+	fmt.Println()
+	for _, s := range md.Settings() {
+		value := s.Value
+		if value == "" {
+			value = "-"
+		}
+		fmt.Printf("%s%-14s%s%-24s %s\n", esccode.Yellow, s.Option, esccode.Reset, value, s.Source)
+	}
+}
@@ -0,0 +1,233 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	cliarg "github.com/ShuaibKhan786/cipher-project/internal/cmdlineargs"
+	esccode "github.com/ShuaibKhan786/cipher-project/internal/escapecode"
+	header "github.com/ShuaibKhan786/cipher-project/internal/header"
+	kdf "github.com/ShuaibKhan786/cipher-project/internal/kdf"
+	passphrase "github.com/ShuaibKhan786/cipher-project/internal/passphrase"
+	recipient "github.com/ShuaibKhan786/cipher-project/internal/recipient"
+	salting "github.com/ShuaibKhan786/cipher-project/internal/salting"
+	secbuf "github.com/ShuaibKhan786/cipher-project/internal/secbuf"
+	input "github.com/ShuaibKhan786/cipher-project/internal/userinp"
+)
+
+const maxGroupAttempts = 3
+
+// credentials hold whatever the user gave to lock or unlock files,
+// a password, the hash of a keyfile, the private key of a recipient
+// or recipients public keys. The secrets stay in secure buffers
+// until destroy.
+type credentials struct {
+	password *secbuf.Buffer
+	// more passwords asked for the files of a batch the first one
+	// didn't open
+	passwords []*secbuf.Buffer
+	// files of a key group the passwords didn't open, while they
+	// opened other groups of the batch
+	mismatched map[string]bool
+	keyfile    *secbuf.Buffer
+	keys       *kdf.Cache
+	identity   *secbuf.Buffer
+	recipients [][]byte
+}
+
+func readCredentials(md *cliarg.ArgsMetaData) (*credentials, error) {
+	creds := &credentials{keys: kdf.NewCache()}
+
+	for _, r := range md.Recipients {
+		publicKey, err := recipient.ParsePublicKey(r)
+		if err != nil {
+			return nil, err
+		}
+		creds.recipients = append(creds.recipients, publicKey)
+	}
+
+	if md.Identity != "" {
+		privateKey, err := recipient.ReadPrivateKey(md.Identity)
+		if err != nil {
+			return nil, err
+		}
+		creds.identity = secbuf.From(privateKey)
+	}
+
+	usesPassword := md.UsesPassword()
+	keyfile := md.Keyfile
+	if !md.Encrypts() && usesPassword {
+		// the headers tell whether a keyfile or a password is needed
+		keyfileOnly, needsPassword := secretNeeds(md.FileNames)
+		if keyfile == "" && len(keyfileOnly) != 0 && !md.JSON {
+			keyfile = input.ReadKeyfilePath(keyfileOnly)
+		}
+		usesPassword = needsPassword
+	}
+
+	if keyfile != "" {
+		hash, err := kdf.HashKeyfile(keyfile)
+		if err != nil {
+			return nil, err
+		}
+		creds.keyfile = secbuf.From(hash)
+	}
+
+	if usesPassword && md.JSON && md.Password.Option == "" {
+		return nil, errors.New(cliarg.JSONPasswordErr)
+	}
+
+	if usesPassword && md.GeneratePassphrase {
+		phrase, err := passphrase.Generate(md.Words)
+		if err != nil {
+			return nil, err
+		}
+		if input.UsePassphraseChoice(phrase, passphrase.Entropy(md.Words)) {
+			creds.password = secbuf.From([]byte(phrase))
+			return creds, nil
+		}
+	}
+
+	if usesPassword {
+		// Read the user input by echo off
+		// a new archive or vault gets its password like encryption,
+		// confirmed and checked for strength
+		operation := md.Operation
+		if md.Encrypts() {
+			operation = cliarg.EncryptionOp
+		}
+		password, err := input.ReadPassword(operation, md.Password, md.MinScore)
+		if err != nil {
+			return nil, err
+		}
+		creds.password = password
+	}
+	return creds, nil
+}
+
+// secrets is what a new password slot is derived from.
+func (c *credentials) secrets() byte {
+	var secrets byte
+	if c.password != nil {
+		secrets |= header.SecretPassword
+	}
+	if c.keyfile != nil {
+		secrets |= header.SecretKeyfile
+	}
+	return secrets
+}
+
+// unlock returns the data key of hdr and the index of the slot
+// that opened it.
+func (c *credentials) unlock(hdr *header.Header) ([]byte, int, error) {
+	if c.identity != nil {
+		return hdr.UnlockIdentity(c.identity.Bytes())
+	}
+	dataKey, index, err := hdr.Unlock(c.password.Bytes(), c.keyfile.Bytes(), c.keys)
+	for _, password := range c.passwords {
+		if !errors.Is(err, header.ErrWrongPassword) {
+			break
+		}
+		dataKey, index, err = hdr.Unlock(password.Bytes(), c.keyfile.Bytes(), c.keys)
+	}
+	return dataKey, index, err
+}
+
+// wrongPassword reports whether no password known so far opens
+// filename, other errors are left for the cipher to report.
+func (c *credentials) wrongPassword(filename string) bool {
+	hdr, err := header.ReadFile(filename)
+	if err != nil {
+		return false
+	}
+	dataKey, _, err := c.unlock(hdr)
+	secbuf.Wipe(dataKey)
+	return errors.Is(err, header.ErrWrongPassword)
+}
+
+// askMissingPasswords goes through the files of a decrypt batch by
+// key group. A group the passwords known so far don't open gets its
+// own password prompt, up to maxGroupAttempts tries, unless the
+// password came from an option. The files of groups left unopened
+// are marked as mismatched when other groups did open.
+func askMissingPasswords(md *cliarg.ArgsMetaData, c *credentials) error {
+	if (md.Operation != cliarg.DecryptionOp && md.Operation != cliarg.VerifyOp) || c.password == nil || c.identity != nil {
+		return nil
+	}
+	prompts := md.Password.Option == ""
+	groups := salting.GroupByKeyParams(md.FileNames)
+	var unopened []salting.KeyGroup
+	for _, group := range groups {
+		for attempt := 0; prompts && attempt < maxGroupAttempts && c.wrongPassword(group.FileNames[0]); attempt++ {
+			password, err := input.ReadGroupPassword(group.FileNames)
+			if err != nil {
+				return err
+			}
+			if password.Len() == 0 {
+				password.Destroy()
+				break
+			}
+			c.passwords = append(c.passwords, password)
+			if c.wrongPassword(group.FileNames[0]) {
+				// a wrong one is not worth trying on the next files
+				c.passwords = c.passwords[:len(c.passwords)-1]
+				password.Destroy()
+				fmt.Println(esccode.Red + header.WrongPasswordErr + esccode.Reset)
+			}
+		}
+		if c.wrongPassword(group.FileNames[0]) {
+			unopened = append(unopened, group)
+		}
+	}
+	if len(unopened) == len(groups) {
+		// nothing opened, it is only a wrong password
+		return nil
+	}
+	c.mismatched = make(map[string]bool)
+	for _, group := range unopened {
+		for _, filename := range group.FileNames {
+			c.mismatched[filename] = true
+		}
+	}
+	return nil
+}
+
+// destroy wipes every secret and the keys derived from them.
+func (c *credentials) destroy() {
+	c.password.Destroy()
+	for _, password := range c.passwords {
+		password.Destroy()
+	}
+	c.keyfile.Destroy()
+	c.identity.Destroy()
+	c.keys.Destroy()
+}
+
+// secretNeeds returns the files whose password slots all need a
+// keyfile, and whether any password slot needs a password. Files
+// that can't be read are left for the cipher to report.
+func secretNeeds(fileNames []string) ([]string, bool) {
+	var keyfileOnly []string
+	needsPassword := false
+	for _, filename := range fileNames {
+		hdr, err := header.ReadFile(filename)
+		if err != nil {
+			needsPassword = true
+			continue
+		}
+
+		allKeyfile, found := true, false
+		for i := range hdr.Slots {
+			if hdr.Slots[i].Type != header.SlotPassword {
+				continue
+			}
+			found = true
+			allKeyfile = allKeyfile && hdr.Slots[i].NeedsKeyfile()
+			needsPassword = needsPassword || hdr.Slots[i].NeedsPassword()
+		}
+		if found && allKeyfile {
+			keyfileOnly = append(keyfileOnly, filename)
+		}
+	}
+	return keyfileOnly, needsPassword
+}
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"fmt"
+
+	cliarg "github.com/ShuaibKhan786/cipher-project/internal/cmdlineargs"
+	esccode "github.com/ShuaibKhan786/cipher-project/internal/escapecode"
+	recipient "github.com/ShuaibKhan786/cipher-project/internal/recipient"
+	result "github.com/ShuaibKhan786/cipher-project/internal/result"
+)
+
+// keygen creates an X25519 key pair, the public key can be handed to
+// anyone who should encrypt files for the owner of the private key.
+func keygen(md *cliarg.ArgsMetaData) []result.Result {
+	filename := md.FileNames[0]
+	public, err := recipient.GenerateKeyPair(filename)
+	if err != nil {
+		fmt.Println(esccode.Red, err.Error(), esccode.Reset)
+		return []result.Result{result.New(filename, err)}
+	}
+
+	fmt.Println(esccode.Green + "Private key: " + filename)
+	fmt.Println("Public key:  " + filename + recipient.PublicKeyExt + esccode.Reset)
+	fmt.Println(public)
+	fmt.Println(esccode.Red + "Keep the private key secret, it can decrypt every file encrypted for it." + esccode.Reset)
+	return []result.Result{result.New(filename, nil)}
+}
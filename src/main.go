@@ -1,7 +1,9 @@
 package main
 
 import (
+	"errors"
 	"fmt"
+	"math"
 	"os"
 	"os/signal"
 	"sync"
@@ -10,25 +12,26 @@ import (
 
 	cipher "github.com/ShuaibKhan786/cipher-project/internal/cipher"
 	cliarg "github.com/ShuaibKhan786/cipher-project/internal/cmdlineargs"
+	esccode "github.com/ShuaibKhan786/cipher-project/internal/escapecode"
+	event "github.com/ShuaibKhan786/cipher-project/internal/event"
+	header "github.com/ShuaibKhan786/cipher-project/internal/header"
+	kdf "github.com/ShuaibKhan786/cipher-project/internal/kdf"
+	progress "github.com/ShuaibKhan786/cipher-project/internal/progress"
+	result "github.com/ShuaibKhan786/cipher-project/internal/result"
 	salting "github.com/ShuaibKhan786/cipher-project/internal/salting"
+	secbuf "github.com/ShuaibKhan786/cipher-project/internal/secbuf"
+	shred "github.com/ShuaibKhan786/cipher-project/internal/shred"
 	input "github.com/ShuaibKhan786/cipher-project/internal/userinp"
-    esccode "github.com/ShuaibKhan786/cipher-project/internal/escapecode" 
-	argon "golang.org/x/crypto/argon2"
+	"golang.org/x/term"
 )
 
 const (
-	saltSize  = 16
-	nonceSize = 12
-	iteration = 1
-	memory    = 64 * 1024
-	thread    = 4
-	keyLength = 32 // i.e. 256 bits
-)
+	saltSize  = header.SaltSize
+	nonceSize = header.NonceSize
 
-type progressBuffer struct {
-	filename   string
-	percentage float64
-}
+	vaultFileErr   = "this file is a vault, use the vault commands"
+	interruptedErr = "Interrupted Sorry"
+)
 
 func main() {
 	//setting up signal handler and a notifier
@@ -37,15 +40,39 @@ func main() {
 
 	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
 
+	// a core dump would hold the password and the keys in clear
+	secbuf.DisableCoreDumps()
+
 	// Constructor for metadata
 	metadata := cliarg.NewArgsMetaData()
 
-	// Validate operation (-e | -d), files
+	// with --json every output is an event on stdout and nothing
+	// is asked
+	var events *event.Emitter
+	if metadata.JSON {
+		events = event.NewEmitter(os.Stdout)
+	}
+
+	// Validate the command, its options and files
 	state, err := metadata.IsValid()
 	if !state {
-		fmt.Println(err)
-		fmt.Println()
-		os.Exit(0)
+		if events != nil {
+			events.Error(err, result.ExitUsage)
+			os.Exit(result.ExitUsage)
+		}
+		// without a command the overview is asked for, anything else
+		// is a mistake
+		if metadata.Operation == "" {
+			fmt.Println(err)
+			fmt.Println()
+			os.Exit(result.ExitOK)
+		}
+		fmt.Fprintln(os.Stderr, esccode.Stderr(err.Error()))
+		os.Exit(result.ExitUsage)
+	}
+	if metadata.Help || metadata.Operation == cliarg.HelpOp {
+		fmt.Print(metadata.HelpText())
+		return
 	}
 
 	//for tracking progress of cipher specially for signal
@@ -55,133 +82,437 @@ func main() {
 	go func() {
 		<-sigs
 		notify <- true
-		cleanup(gtracker, &metadata)
+		cleanup(gtracker, &metadata, events)
 	}()
 
-	// Read the user input by echo off
-	password, _ := input.ReadPassword(metadata.Operation)
+	// Listing the slots only reads the plaintext part of the header,
+	// these commands report their errors inline and exit like a batch
+	var results []result.Result
+	switch metadata.Operation {
+	case cliarg.ListSlotsOp:
+		results = listSlots(&metadata)
+	case cliarg.InspectOp:
+		results = inspect(&metadata)
+	case cliarg.ConfigOp:
+		showConfig(&metadata)
+		return
+	case cliarg.KeygenOp:
+		results = keygen(&metadata)
+	case cliarg.GeneratePassphraseOp:
+		if err := generatePassphrase(&metadata); err != nil {
+			fatal(events, err)
+		}
+		return
+	}
+	if results != nil {
+		os.Exit(result.ExitCode(results))
+	}
+
+	creds, err := readCredentials(&metadata)
+	if err != nil {
+		fatal(events, err)
+	}
+	defer creds.destroy()
 
 	start := time.Now()
-	// Generate a salt and nonce for each files
-	pair := salting.NewSaltNoncePair(saltSize, nonceSize, metadata.NumOfFiles)
-	pair.GenerateSaltNoncePair(&metadata)
 
-	// Key derivation of key-size 256 bits
-	aes256key := argon.IDKey(password, pair.S, iteration, memory, thread, keyLength)
+	switch metadata.Operation {
+	case cliarg.RekeyOp:
+		results = rekey(&metadata, creds)
+	case cliarg.MigrateOp:
+		results = migrate(&metadata, creds)
+	case cliarg.AddSlotOp:
+		results = addSlot(&metadata, creds)
+	case cliarg.RemoveSlotOp:
+		results = removeSlot(&metadata, creds)
+	case cliarg.PackOp:
+		results = pack(&metadata, creds)
+	case cliarg.UnpackOp:
+		results = unpack(&metadata, creds)
+	case cliarg.VaultOp:
+		results = vaultCommand(&metadata, creds)
+	}
+	if !metadata.Batch() {
+		result.PrintSummary(os.Stdout, results)
+		fmt.Printf("%v\nIt took %v%v\n", esccode.White, time.Since(start), esccode.Reset)
+		creds.destroy()
+		os.Exit(result.ExitCode(results))
+	}
+
+	// Generate a salt and nonce for each files, decrypted files
+	// bring theirs in the header and may need more passwords
+	pair := salting.NewSaltNoncePair(saltSize, nonceSize, metadata.NumOfFiles)
+	if metadata.Operation == cliarg.EncryptionOp {
+		if err := pair.GenerateSaltNoncePair(); err != nil {
+			creds.destroy()
+			fatal(events, err)
+		}
+	}
+	if err := askMissingPasswords(&metadata, creds); err != nil {
+		creds.destroy()
+		fatal(events, err)
+	}
 
-	// using go routine
-	// to handle mutiple file cipher process
+	// A fixed pool of workers, a file is only prepared once a worker
+	// takes it, so the open files, buffers and keys are bounded by
+	// the number of jobs whatever the number of files
 	var progressWg sync.WaitGroup
 	var workerWg sync.WaitGroup
-	channel := make(chan cipher.CipherProgress, metadata.NumOfFiles)
+	channel := make(chan cipher.CipherProgress, metadata.Jobs)
+	jobs := make(chan func())
+	// every job writes the result of its own file
+	results = make([]result.Result, metadata.NumOfFiles)
+	report := func(index int, err error) {
+		results[index] = result.New(metadata.FileNames[index], err)
+	}
+	// the result event of a file follows its last progress event,
+	// once the file is finished
+	indexes := make(map[string]int, metadata.NumOfFiles)
+	for index, filename := range metadata.FileNames {
+		indexes[filename] = index
+	}
+	emitResult := func(filename string) {
+		index := indexes[filename]
+		output := metadata.Output(filename)
+		if metadata.Operation == cliarg.VerifyOp {
+			output = ""
+		}
+		events.Result(results[index], output)
+	}
+	if events != nil {
+		events.Start(metadata.Operation, metadata.NumOfFiles, metadata.Jobs)
+	}
 
 	// progress tracker for worker
 	progressWg.Add(1)
 	go func(progressWg *sync.WaitGroup) {
 		defer progressWg.Done()
-		displayProgress(metadata.FileNames, channel, notify)
+		if events != nil {
+			emitProgress(events, channel, notify, emitResult)
+			return
+		}
+		displayProgress(progress.New(os.Stdout, metadata.FileNames), channel, notify)
 	}(&progressWg)
 
+	for i := 0; i < min(metadata.Jobs, metadata.NumOfFiles); i++ {
+		workerWg.Add(1)
+		go func() {
+			defer workerWg.Done()
+			for job := range jobs {
+				job()
+			}
+		}()
+	}
+
+	removes, asks := removal(&metadata)
 	if metadata.Operation == cliarg.EncryptionOp {
+		// the originals may only be removed once their output is
+		// read back, while the data key is still at hand
+		verifies := removes
 		for index, filename := range metadata.FileNames {
-			workerWg.Add(1)
+			hdr, dataKey, err := newHeader(creds, pair.S, pair.NN[index])
+			if err != nil {
+				report(index, err)
+				finished(channel, filename)
+				continue
+			}
 			encMetadata := cipher.EncryptionMetadata{
 				Filename: filename,
-				Key:      aes256key,
-				Nonce:    pair.NN[index],
-				Salt:     pair.S,
-			}
-			go func(md cipher.EncryptionMetadata) {
-				defer workerWg.Done()
-				if err := cipher.Encryption(md, channel, gtracker); err != nil {
-					fmt.Println(esccode.Red)
-					fmt.Println(err.Error(), esccode.Reset)
-					fmt.Println()
+				Output:   metadata.Output(filename),
+				Force:    metadata.Force,
+				Key:      dataKey,
+				Header:   hdr,
+			}
+			jobs <- func() {
+				defer finished(channel, encMetadata.Filename)
+				defer encMetadata.Key.Destroy()
+				err := cipher.Encryption(encMetadata, channel, gtracker)
+				if err == nil && verifies {
+					err = verifyOutput(encMetadata)
 				}
-			}(encMetadata)
+				report(index, err)
+			}
 		}
 	} else {
+		// verifying goes through the same files without writing any
+		open := func(md cipher.DecryptionMetadata) error {
+			return cipher.Decryption(md, channel, gtracker)
+		}
+		if metadata.Operation == cliarg.VerifyOp {
+			open = func(md cipher.DecryptionMetadata) error {
+				return cipher.Verify(md, channel)
+			}
+		}
 		for index, filename := range metadata.FileNames {
-			workerWg.Add(1)
+			hdr, dataKey, _, err := openStream(filename, creds)
+			if err != nil {
+				report(index, err)
+				finished(channel, filename)
+				continue
+			}
 			decMetadata := cipher.DecryptionMetadata{
 				Filename: filename,
-				Key:      aes256key,
-				Nonce:    pair.NN[index],
-				SeekSize: int64(len(pair.S) + len(pair.NN[index])),
-			}
-			go func(md cipher.DecryptionMetadata) {
-				defer workerWg.Done()
-				if err := cipher.Decryption(md, channel, gtracker); err != nil {
-					if err.Error() == "cipher: message authentication failed" {
-						fmt.Println(esccode.Red,"\tWrong Password",esccode.Reset)
-					} 
-					fmt.Println(err.Error())
-				}
-			}(decMetadata)
+				Output:   metadata.Output(filename),
+				Force:    metadata.Force,
+				Key:      dataKey,
+				Nonce:    hdr.Nonce,
+				SeekSize: hdr.Size(),
+			}
+			jobs <- func() {
+				defer finished(channel, decMetadata.Filename)
+				defer decMetadata.Key.Destroy()
+				report(index, open(decMetadata))
+			}
 		}
 	}
 
+	close(jobs)
 	workerWg.Wait()
 	close(channel)
 	progressWg.Wait()
 
 	end := time.Now()
 
-	if metadata.Operation == cliarg.EncryptionOp {
-		if input.DeleteAllfilesChoice() {
-			removesAllfiles(metadata.FileNames)
+	if events == nil {
+		result.PrintSummary(os.Stdout, results)
+	}
+
+	// only the inputs whose output was written, and read back for
+	// an encryption, can be removed
+	succeeded := result.Succeeded(results)
+	if removes && len(succeeded) != 0 {
+		if metadata.Shred {
+			if events != nil {
+				fmt.Fprintln(os.Stderr, esccode.Stderr(esccode.Yellow+"WARNING: "+shred.Warning+esccode.Reset))
+			} else {
+				fmt.Println(esccode.Yellow + "\nWARNING: " + shred.Warning + esccode.Reset)
+			}
+		}
+		if !asks || input.DeleteAllfilesChoice() {
+			removesAllfiles(&metadata, succeeded, events)
 		}
 	}
 
+	if events != nil {
+		events.Summary(results, end.Sub(start))
+		creds.destroy()
+		os.Exit(result.ExitCode(results))
+	}
+
 	elapsed := end.Sub(start)
 	fmt.Printf("%v\nIt took %v%v\n", esccode.White, elapsed, esccode.Reset)
+
+	creds.destroy()
+	os.Exit(result.ExitCode(results))
 }
 
-func displayProgress(fileNames []string, channel <-chan cipher.CipherProgress, notify chan bool) {
-	filesProgressBuffer := make(map[string]*progressBuffer)
-	fmt.Println()
-	for _, file := range fileNames {
-		filesProgressBuffer[file] = &progressBuffer{filename: file}
-		fmt.Println()
+// newHeader creates a random data key for one file and wraps it
+// with the password and keyfile key and for every recipient.
+func newHeader(creds *credentials, salt salting.Salt, nonce salting.Nonce) (*header.Header, *secbuf.Buffer, error) {
+	key, err := header.NewDataKey()
+	if err != nil {
+		return nil, nil, err
 	}
+	dataKey := secbuf.From(key)
+
+	hdr, err := wrapDataKey(creds, dataKey.Bytes(), nonce, salt)
+	if err != nil {
+		dataKey.Destroy()
+		return nil, nil, err
+	}
+	return hdr, dataKey, nil
+}
+
+func wrapDataKey(creds *credentials, dataKey []byte, nonce salting.Nonce, salt salting.Salt) (*header.Header, error) {
+	var slots []header.Slot
+	if secrets := creds.secrets(); secrets != 0 {
+		// Key derivation of key-size 256 bits, cached per salt
+		secret := kdf.Secret(creds.password.Bytes(), creds.keyfile.Bytes())
+		aes256key := creds.keys.IDKey(secret, salt, kdf.DefaultParams)
+		secbuf.Wipe(secret)
+		slot, err := header.NewSecretSlot(dataKey, aes256key, salt, kdf.DefaultParams, secrets)
+		if err != nil {
+			return nil, err
+		}
+		slots = append(slots, slot)
+	}
+	for _, publicKey := range creds.recipients {
+		slot, err := header.NewRecipientSlot(dataKey, publicKey)
+		if err != nil {
+			return nil, err
+		}
+		slots = append(slots, slot)
+	}
+
+	hdr := header.New(nonce)
+	for _, slot := range slots {
+		if _, err := hdr.AddSlot(slot); err != nil {
+			return nil, err
+		}
+	}
+	return hdr, nil
+}
+
+// openHeader reads the header of filename and unwraps its data key,
+// returning the index of the slot it matched. The caller destroys
+// the data key.
+func openHeader(filename string, creds *credentials) (*header.Header, *secbuf.Buffer, int, error) {
+	hdr, err := header.ReadFile(filename)
+	if err != nil {
+		return nil, nil, -1, err
+	}
+	dataKey, index, err := creds.unlock(hdr)
+	if errors.Is(err, header.ErrWrongPassword) && creds.mismatched[filename] {
+		return nil, nil, -1, salting.SaltMismatch(filename)
+	}
+	if err != nil {
+		return nil, nil, -1, &os.PathError{Op: "unlock", Path: filename, Err: err}
+	}
+	return hdr, secbuf.From(dataKey), index, nil
+}
+
+// openStream is openHeader for files holding a single stream, a
+// vault is refused instead of failing on its first chunk.
+func openStream(filename string, creds *credentials) (*header.Header, *secbuf.Buffer, int, error) {
+	hdr, dataKey, index, err := openHeader(filename, creds)
+	if err != nil {
+		return nil, nil, -1, err
+	}
+	if hdr.Flags&header.FlagVault != 0 {
+		dataKey.Destroy()
+		return nil, nil, -1, result.Skip(&os.PathError{Op: "open", Path: filename, Err: errors.New(vaultFileErr)})
+	}
+	return hdr, dataKey, index, nil
+}
+
+// finished tells the progress display a worker is done with filename.
+func finished(channel chan<- cipher.CipherProgress, filename string) {
+	channel <- cipher.CipherProgress{Filename: filename, Done: true}
+}
+
+// emitProgress sends a progress event whenever the whole percent
+// of a file changes, and calls done once the file is finished.
+func emitProgress(events *event.Emitter, channel <-chan cipher.CipherProgress, notify chan bool, done func(filename string)) {
+	percents := make(map[string]float64)
 	for {
 		select {
 		case <-notify:
-			fmt.Println(esccode.Reset)
 			return
 		case progress, ok := <-channel:
 			if !ok {
-				fmt.Println(esccode.Reset)
 				return
 			}
-			if pb, ok := filesProgressBuffer[progress.Filename]; ok {
-				pb.percentage = progress.Percentage
+			if progress.Done {
+				delete(percents, progress.Filename)
+				done(progress.Filename)
+				continue
 			}
-			for range filesProgressBuffer {
-				fmt.Print(esccode.MvCrUpClrLine)
+			percent := math.Floor(progress.Percentage)
+			if last, ok := percents[progress.Filename]; ok && last == percent {
+				continue
 			}
-			for _, v := range filesProgressBuffer {
-				fmt.Printf("%s\t%.15s...\t\t%.0f%%\n", esccode.Cyan, v.filename, v.percentage)
+			percents[progress.Filename] = percent
+			events.Progress(progress.Filename, percent)
+		}
+	}
+}
+
+// displayProgress feeds the renderer until the batch is done or
+// interrupted.
+func displayProgress(renderer *progress.Renderer, channel <-chan cipher.CipherProgress, notify chan bool) {
+	fmt.Println()
+	for {
+		select {
+		case <-notify:
+			return
+		case p, ok := <-channel:
+			if !ok {
+				renderer.Close()
+				return
+			}
+			if p.Done {
+				renderer.Finish(p.Filename)
+				continue
 			}
+			renderer.Update(p.Filename, p.Read, p.Size)
+		}
+	}
+}
+
+// verifyOutput reads back the output of an encryption with its data
+// key. An output which doesn't open is removed, so it fails like any
+// other encryption and its original is kept.
+func verifyOutput(md cipher.EncryptionMetadata) error {
+	err := cipher.Verify(cipher.DecryptionMetadata{
+		Filename: md.Output,
+		Key:      md.Key,
+		Nonce:    md.Header.Nonce,
+		SeekSize: md.Header.Size(),
+	}, nil)
+	if err != nil {
+		os.Remove(md.Output)
+	}
+	return err
+}
+
+// removal tells whether the inputs of a batch are removed once it is
+// done, and whether the user is asked first. The originals of an
+// encryption are asked about only on a terminal, decrypted files are
+// removed only with md.DeleteEncrypted.
+func removal(md *cliarg.ArgsMetaData) (removes, asks bool) {
+	switch md.Operation {
+	case cliarg.EncryptionOp:
+		if md.KeepOriginals {
+			return false, false
 		}
+		if md.DeleteOriginals {
+			return true, false
+		}
+		if md.JSON || !term.IsTerminal(int(os.Stdin.Fd())) {
+			return false, false
+		}
+		return true, true
+	case cliarg.DecryptionOp:
+		return md.DeleteEncrypted, false
 	}
+	return false, false
 }
 
-func removesAllfiles(fileNames []string) {
+// removesAllfiles removes the inputs, overwriting them first with
+// md.Shred. A file which can't be removed is reported and kept.
+func removesAllfiles(md *cliarg.ArgsMetaData, fileNames []string, events *event.Emitter) {
+	removed := 0
 	for _, filename := range fileNames {
-		os.Remove(filename)
+		var err error
+		if md.Shred {
+			err = shred.File(filename, md.Passes)
+		} else {
+			err = os.Remove(filename)
+		}
+		if events != nil {
+			events.Removed(filename, err)
+			continue
+		}
+		if err != nil {
+			fmt.Println(esccode.Red + err.Error() + esccode.Reset)
+			continue
+		}
+		removed++
+	}
+	if events == nil {
+		fmt.Printf("%sRemoved %d of %d files%s\n", esccode.White, removed, len(fileNames), esccode.Reset)
 	}
 }
 
-func cleanup(gtracker *cipher.GlobalProgressTracker, md *cliarg.ArgsMetaData) {
+func cleanup(gtracker *cipher.GlobalProgressTracker, md *cliarg.ArgsMetaData, events *event.Emitter) {
 	gtracker.Mu.Lock()
 	for _, filename := range md.FileNames {
 		if gt, ok := gtracker.Tracker[filename]; ok {
-			if !gt.Tracker {
-				if md.Operation == cliarg.EncryptionOp {
-					os.Remove(filename+cliarg.EncryptedFileExt)
-				}else {
-					os.Remove(filename[:len(filename)-len(cliarg.EncryptedFileExt)])
+			// only the files being written, the others were never
+			// started or are already finished or removed
+			if !gt.Tracker && gt.Fpair.Wfile != nil {
+				if md.Operation == cliarg.EncryptionOp || md.Operation == cliarg.DecryptionOp {
+					os.Remove(md.Output(filename))
 				}
 			}
 			if gt.Fpair.Rfile != nil {
@@ -193,6 +524,37 @@ func cleanup(gtracker *cipher.GlobalProgressTracker, md *cliarg.ArgsMetaData) {
 		}
 	}
 	gtracker.Mu.Unlock()
-	fmt.Printf("\n%s%s%s\n", esccode.Red, "Interrupted Sorry", esccode.Reset)
+	if events != nil {
+		events.Error(errors.New(interruptedErr), 1)
+		os.Exit(1)
+	}
+	fmt.Printf("\n%s%s%s\n", esccode.Red, interruptedErr, esccode.Reset)
+	os.Exit(1)
+}
+
+// failAll is the result of every file of a command which failed
+// before any of them was done.
+func failAll(filenames []string, err error) []result.Result {
+	results := make([]result.Result, len(filenames))
+	for i, filename := range filenames {
+		results[i] = result.New(filename, err)
+	}
+	return results
+}
+
+// usage reports a command line mistake only found once the command
+// runs, it exits like the ones found by IsValid.
+func usage(err error) {
+	fmt.Println(esccode.Red, err.Error(), esccode.Reset)
+	os.Exit(result.ExitUsage)
+}
+
+// fatal reports an error which stops the whole run.
+func fatal(events *event.Emitter, err error) {
+	if events != nil {
+		events.Error(err, 1)
+		os.Exit(1)
+	}
+	fmt.Println(esccode.Red, err.Error(), esccode.Reset)
 	os.Exit(1)
 }
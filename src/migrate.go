@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	cipher "github.com/ShuaibKhan786/cipher-project/internal/cipher"
+	cliarg "github.com/ShuaibKhan786/cipher-project/internal/cmdlineargs"
+	esccode "github.com/ShuaibKhan786/cipher-project/internal/escapecode"
+	header "github.com/ShuaibKhan786/cipher-project/internal/header"
+	legacy "github.com/ShuaibKhan786/cipher-project/internal/legacy"
+	result "github.com/ShuaibKhan786/cipher-project/internal/result"
+	salting "github.com/ShuaibKhan786/cipher-project/internal/salting"
+	secbuf "github.com/ShuaibKhan786/cipher-project/internal/secbuf"
+)
+
+// migrate rewrites files made before the file header in the current
+// format, under a password slot of the same password. The files of
+// a batch share the salt of their new slots, like an encryption.
+func migrate(md *cliarg.ArgsMetaData, creds *credentials) []result.Result {
+	salt, err := salting.NewRandomSalt(saltSize)
+	if err != nil {
+		return failAll(md.FileNames, err)
+	}
+
+	results := make([]result.Result, len(md.FileNames))
+	for i, filename := range md.FileNames {
+		results[i] = result.New(filename, migrateFile(filename, creds, salt))
+		if results[i].Status == result.OK {
+			fmt.Println(esccode.Green, filename, "migrated", esccode.Reset)
+		}
+	}
+	return results
+}
+
+// migrateFile decrypts filename into a new file next to it, which is
+// renamed over it once every chunk opened. A file failing half way
+// is left as it was.
+func migrateFile(filename string, creds *credentials, salt salting.Salt) error {
+	file, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	oldSalt, oldNonce, err := legacy.ReadHeader(file)
+	if errors.Is(err, legacy.ErrCurrentFormat) {
+		return result.Skip(&os.PathError{Op: "migrate", Path: filename, Err: err})
+	}
+	if err != nil {
+		return &os.PathError{Op: "migrate", Path: filename, Err: err}
+	}
+	key := secbuf.From(bytes.Clone(creds.keys.IDKey(creds.password.Bytes(), oldSalt, legacy.Params)))
+	defer key.Destroy()
+	reader, err := legacy.NewReader(file, key, oldNonce)
+	if err != nil {
+		return err
+	}
+
+	nonce, err := salting.NewRandomNonce(nonceSize)
+	if err != nil {
+		return err
+	}
+	hdr, dataKey, err := newHeader(creds, salt, nonce)
+	if err != nil {
+		return err
+	}
+	defer dataKey.Destroy()
+
+	tmp, err := os.CreateTemp(filepath.Dir(filename), filepath.Base(filename)+".migrate-*")
+	if err != nil {
+		return err
+	}
+	err = copyEncrypted(tmp, reader, dataKey, hdr)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		if info, statErr := file.Stat(); statErr == nil {
+			os.Chmod(tmp.Name(), info.Mode().Perm())
+		}
+		err = os.Rename(tmp.Name(), filename)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return &os.PathError{Op: "migrate", Path: filename, Err: err}
+	}
+	return nil
+}
+
+func copyEncrypted(w io.Writer, r io.Reader, dataKey *secbuf.Buffer, hdr *header.Header) error {
+	writer, err := cipher.NewWriter(w, dataKey, hdr)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(writer, r); err != nil {
+		return err
+	}
+	return writer.Close()
+}
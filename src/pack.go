@@ -0,0 +1,113 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+
+	archive "github.com/ShuaibKhan786/cipher-project/internal/archive"
+	cipher "github.com/ShuaibKhan786/cipher-project/internal/cipher"
+	cliarg "github.com/ShuaibKhan786/cipher-project/internal/cmdlineargs"
+	esccode "github.com/ShuaibKhan786/cipher-project/internal/escapecode"
+	result "github.com/ShuaibKhan786/cipher-project/internal/result"
+	salting "github.com/ShuaibKhan786/cipher-project/internal/salting"
+	walk "github.com/ShuaibKhan786/cipher-project/internal/walk"
+)
+
+// pack streams the files and directories given after the archive
+// name into a single tar archive, encrypted like any other file.
+func pack(md *cliarg.ArgsMetaData, creds *credentials) []result.Result {
+	name := md.FileNames[0]
+	entries, err := walk.Files(md.FileNames[1:], md.Walk, nil)
+	if err == nil {
+		err = packArchive(name, entries, creds)
+	}
+	if err == nil {
+		fmt.Printf("%s %d files packed into %s%s\n", esccode.Green, len(entries), name, esccode.Reset)
+	}
+	return []result.Result{result.New(name, err)}
+}
+
+func packArchive(name string, entries []walk.Entry, creds *credentials) error {
+	salt, err := salting.NewRandomSalt(saltSize)
+	if err != nil {
+		return err
+	}
+	nonce, err := salting.NewRandomNonce(nonceSize)
+	if err != nil {
+		return err
+	}
+	hdr, dataKey, err := newHeader(creds, salt, nonce)
+	if err != nil {
+		return err
+	}
+	defer dataKey.Destroy()
+
+	file, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return err
+	}
+	buffered := bufio.NewWriter(file)
+	err = func() error {
+		w, err := cipher.NewWriter(buffered, dataKey, hdr)
+		if err != nil {
+			return err
+		}
+		if err := archive.Pack(w, entries); err != nil {
+			return err
+		}
+		if err := w.Close(); err != nil {
+			return err
+		}
+		return buffered.Flush()
+	}()
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(name)
+	}
+	return err
+}
+
+// unpack restores every archive under the output root, the current
+// directory by default.
+func unpack(md *cliarg.ArgsMetaData, creds *credentials) []result.Result {
+	root := md.OutputDir
+	if root == "" {
+		root = "."
+	}
+	results := make([]result.Result, len(md.FileNames))
+	for i, name := range md.FileNames {
+		count, err := unpackArchive(name, root, creds)
+		results[i] = result.New(name, err)
+		if err == nil {
+			fmt.Printf("%s %d files unpacked from %s%s\n", esccode.Green, count, name, esccode.Reset)
+		}
+	}
+	return results
+}
+
+func unpackArchive(name, root string, creds *credentials) (int, error) {
+	hdr, dataKey, _, err := openStream(name, creds)
+	if err != nil {
+		return 0, err
+	}
+	defer dataKey.Destroy()
+
+	file, err := os.Open(name)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+	if _, err := file.Seek(hdr.Size(), io.SeekStart); err != nil {
+		return 0, err
+	}
+
+	r, err := cipher.NewReader(file, dataKey, hdr.Nonce)
+	if err != nil {
+		return 0, err
+	}
+	return archive.Unpack(r, root)
+}
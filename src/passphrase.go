@@ -0,0 +1,22 @@
+package main
+
+import (
+	"fmt"
+
+	cliarg "github.com/ShuaibKhan786/cipher-project/internal/cmdlineargs"
+	esccode "github.com/ShuaibKhan786/cipher-project/internal/escapecode"
+	passphrase "github.com/ShuaibKhan786/cipher-project/internal/passphrase"
+)
+
+// generatePassphrase prints a diceware passphrase to use later as a
+// password, nothing is written to disk.
+func generatePassphrase(md *cliarg.ArgsMetaData) error {
+	phrase, err := passphrase.Generate(md.Words)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(phrase)
+	fmt.Printf("%s%d words, %.0f bits of entropy%s\n", esccode.Green, md.Words, passphrase.Entropy(md.Words), esccode.Reset)
+	return nil
+}
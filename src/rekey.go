@@ -0,0 +1,89 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	cliarg "github.com/ShuaibKhan786/cipher-project/internal/cmdlineargs"
+	esccode "github.com/ShuaibKhan786/cipher-project/internal/escapecode"
+	header "github.com/ShuaibKhan786/cipher-project/internal/header"
+	kdf "github.com/ShuaibKhan786/cipher-project/internal/kdf"
+	result "github.com/ShuaibKhan786/cipher-project/internal/result"
+	salting "github.com/ShuaibKhan786/cipher-project/internal/salting"
+	secbuf "github.com/ShuaibKhan786/cipher-project/internal/secbuf"
+	input "github.com/ShuaibKhan786/cipher-project/internal/userinp"
+)
+
+const noPasswordSlotErr = "the key slot opened has no password to change"
+
+// rekey changes the password of every file by rewrapping its data key
+// under the new password. Only the header is rewritten, the encrypted
+// content stays as it is. The current password is checked first, the
+// new one is only asked for once it opens some of the files.
+func rekey(md *cliarg.ArgsMetaData, creds *credentials) []result.Result {
+	results, opened := checkFiles(md.FileNames, func(filename string) error {
+		return checkRekey(filename, creds)
+	})
+	if len(opened) == 0 {
+		return results
+	}
+
+	newPassword, err := input.ReadNewPassword(md.MinScore)
+	if err != nil {
+		return failOpened(results, opened, err)
+	}
+	defer newPassword.Destroy()
+
+	salt, err := salting.NewRandomSalt(saltSize)
+	if err != nil {
+		return failOpened(results, opened, err)
+	}
+
+	for _, i := range opened {
+		filename := md.FileNames[i]
+		err := rekeyFile(filename, creds, newPassword, salt)
+		results[i] = result.New(filename, err)
+		if err == nil {
+			fmt.Println(esccode.Green, filename, "password changed", esccode.Reset)
+		}
+	}
+	return results
+}
+
+// checkRekey reports why the password of filename can't be changed
+// with creds, if it can't.
+func checkRekey(filename string, creds *credentials) error {
+	hdr, dataKey, index, err := openHeader(filename, creds)
+	if err != nil {
+		return err
+	}
+	dataKey.Destroy()
+	if !hdr.Slots[index].NeedsPassword() {
+		return errors.New(noPasswordSlotErr)
+	}
+	return nil
+}
+
+func rekeyFile(filename string, creds *credentials, newPassword *secbuf.Buffer, salt salting.Salt) error {
+	hdr, dataKey, index, err := openHeader(filename, creds)
+	if err != nil {
+		return err
+	}
+	defer dataKey.Destroy()
+	slot := hdr.Slots[index]
+	if !slot.NeedsPassword() {
+		return errors.New(noPasswordSlotErr)
+	}
+
+	// only the slot opened by the current password is replaced, the
+	// other slots keep their own passwords, a keyfile stays required
+	secret := slot.Secret(newPassword.Bytes(), creds.keyfile.Bytes())
+	newKey := creds.keys.IDKey(secret, salt, kdf.DefaultParams)
+	secbuf.Wipe(secret)
+	newSlot, err := header.NewSecretSlot(dataKey.Bytes(), newKey, salt, kdf.DefaultParams, slot.Secrets)
+	if err != nil {
+		return err
+	}
+	hdr.Slots[index] = newSlot
+	return hdr.Rewrite(filename)
+}
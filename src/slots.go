@@ -0,0 +1,199 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	cliarg "github.com/ShuaibKhan786/cipher-project/internal/cmdlineargs"
+	esccode "github.com/ShuaibKhan786/cipher-project/internal/escapecode"
+	header "github.com/ShuaibKhan786/cipher-project/internal/header"
+	kdf "github.com/ShuaibKhan786/cipher-project/internal/kdf"
+	result "github.com/ShuaibKhan786/cipher-project/internal/result"
+	salting "github.com/ShuaibKhan786/cipher-project/internal/salting"
+	secbuf "github.com/ShuaibKhan786/cipher-project/internal/secbuf"
+	input "github.com/ShuaibKhan786/cipher-project/internal/userinp"
+)
+
+// listSlots prints the used key slots of every file. The slot table
+// is not encrypted, so no password is needed.
+func listSlots(md *cliarg.ArgsMetaData) []result.Result {
+	results := make([]result.Result, len(md.FileNames))
+	for i, filename := range md.FileNames {
+		hdr, err := header.ReadFile(filename)
+		results[i] = result.New(filename, err)
+		if err != nil {
+			fmt.Println(esccode.Red, filename, err.Error(), esccode.Reset)
+			continue
+		}
+		fmt.Println(esccode.Cyan + filename + esccode.Reset)
+		for i, slot := range hdr.Slots {
+			if slot.Type == header.SlotEmpty {
+				continue
+			}
+			fmt.Printf("\tslot %d: %s\n", i, describeSlot(slot))
+		}
+	}
+	return results
+}
+
+// inspect prints what the header of every file tells without a
+// password, its format, kind, size and key slots.
+func inspect(md *cliarg.ArgsMetaData) []result.Result {
+	results := make([]result.Result, len(md.FileNames))
+	for i, filename := range md.FileNames {
+		hdr, err := header.ReadFile(filename)
+		var info os.FileInfo
+		if err == nil {
+			info, err = os.Stat(filename)
+		}
+		results[i] = result.New(filename, err)
+		if err != nil {
+			fmt.Println(esccode.Red, err.Error(), esccode.Reset)
+			continue
+		}
+		kind := "file"
+		if hdr.Flags&header.FlagVault != 0 {
+			kind = "vault"
+		}
+		fmt.Println(esccode.Cyan + filename + esccode.Reset)
+		fmt.Printf("\tformat version %d, %s\n", hdr.Version, kind)
+		fmt.Printf("\tsize %d bytes, header %d bytes\n", info.Size(), hdr.Size())
+		fmt.Printf("\tkey slots %d of %d\n", hdr.UsedSlots(), header.MaxSlots)
+		for i, slot := range hdr.Slots {
+			if slot.Type == header.SlotEmpty {
+				continue
+			}
+			fmt.Printf("\tslot %d: %s\n", i, describeSlot(slot))
+		}
+	}
+	return results
+}
+
+// addSlot wraps the data key of every file with an additional
+// password. One of the existing passwords, or the private key of a
+// recipient, is needed to unwrap it, and is checked on every file
+// before the new password is asked for.
+func addSlot(md *cliarg.ArgsMetaData, creds *credentials) []result.Result {
+	results, opened := checkFiles(md.FileNames, func(filename string) error {
+		_, dataKey, _, err := openHeader(filename, creds)
+		dataKey.Destroy()
+		return err
+	})
+	if len(opened) == 0 {
+		return results
+	}
+
+	newPassword, err := input.ReadNewPassword(md.MinScore)
+	if err != nil {
+		return failOpened(results, opened, err)
+	}
+
+	salt, err := salting.NewRandomSalt(saltSize)
+	if err != nil {
+		newPassword.Destroy()
+		return failOpened(results, opened, err)
+	}
+	newKey := secbuf.From(kdf.IDKey(newPassword.Bytes(), salt, kdf.DefaultParams))
+	newPassword.Destroy()
+	defer newKey.Destroy()
+
+	for _, i := range opened {
+		filename := md.FileNames[i]
+		index, err := addSlotFile(filename, creds, newKey, salt)
+		results[i] = result.New(filename, err)
+		if err == nil {
+			fmt.Printf("%s %s password added to slot %d%s\n", esccode.Green, filename, index, esccode.Reset)
+		}
+	}
+	return results
+}
+
+// checkFiles runs check on every file, the ones which pass are the
+// indexes in opened, which a command goes on with once it asked for
+// a new password.
+func checkFiles(filenames []string, check func(filename string) error) (results []result.Result, opened []int) {
+	results = make([]result.Result, len(filenames))
+	for i, filename := range filenames {
+		results[i] = result.New(filename, check(filename))
+		if results[i].Status == result.OK {
+			opened = append(opened, i)
+		}
+	}
+	return results, opened
+}
+
+// failOpened reports err for the files checkFiles let through, when
+// the new password can't be had.
+func failOpened(results []result.Result, opened []int, err error) []result.Result {
+	for _, i := range opened {
+		results[i] = result.New(results[i].Filename, err)
+	}
+	return results
+}
+
+func addSlotFile(filename string, creds *credentials, newKey *secbuf.Buffer, salt salting.Salt) (int, error) {
+	hdr, dataKey, _, err := openHeader(filename, creds)
+	if err != nil {
+		return -1, err
+	}
+	defer dataKey.Destroy()
+
+	slot, err := header.NewPasswordSlot(dataKey.Bytes(), newKey.Bytes(), salt, kdf.DefaultParams)
+	if err != nil {
+		return -1, err
+	}
+	index, err := hdr.AddSlot(slot)
+	if err != nil {
+		return -1, err
+	}
+	return index, hdr.Rewrite(filename)
+}
+
+// removeSlot empties the chosen slot of every file after checking
+// that the password opens the file. A slot number which no file can
+// have is a usage error, like a wrong option.
+func removeSlot(md *cliarg.ArgsMetaData, creds *credentials) []result.Result {
+	listSlots(md)
+	index := input.ReadSlotNumber()
+	if index < 0 || index >= header.MaxSlots {
+		creds.destroy()
+		usage(errors.New(header.InvalidSlotErr))
+	}
+
+	results := make([]result.Result, len(md.FileNames))
+	for i, filename := range md.FileNames {
+		hdr, dataKey, _, err := openHeader(filename, creds)
+		dataKey.Destroy()
+		if err == nil {
+			err = hdr.RemoveSlot(index)
+		}
+		if err == nil {
+			err = hdr.Rewrite(filename)
+		}
+		results[i] = result.New(filename, err)
+		if err == nil {
+			fmt.Printf("%s %s slot %d removed%s\n", esccode.Green, filename, index, esccode.Reset)
+		}
+	}
+	return results
+}
+
+func describeSlot(slot header.Slot) string {
+	switch slot.Type {
+	case header.SlotPassword:
+		kind := "password"
+		switch {
+		case slot.NeedsPassword() && slot.NeedsKeyfile():
+			kind = "password and keyfile"
+		case slot.NeedsKeyfile():
+			kind = "keyfile"
+		}
+		return fmt.Sprintf("%s (argon2id, iterations %d, memory %d KiB, threads %d)",
+			kind, slot.Params.Iteration, slot.Params.Memory, slot.Params.Thread)
+	case header.SlotX25519:
+		return "x25519 recipient"
+	default:
+		return fmt.Sprintf("unknown type %d", slot.Type)
+	}
+}
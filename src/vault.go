@@ -0,0 +1,187 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	archive "github.com/ShuaibKhan786/cipher-project/internal/archive"
+	cliarg "github.com/ShuaibKhan786/cipher-project/internal/cmdlineargs"
+	esccode "github.com/ShuaibKhan786/cipher-project/internal/escapecode"
+	result "github.com/ShuaibKhan786/cipher-project/internal/result"
+	salting "github.com/ShuaibKhan786/cipher-project/internal/salting"
+	vault "github.com/ShuaibKhan786/cipher-project/internal/vault"
+	walk "github.com/ShuaibKhan786/cipher-project/internal/walk"
+)
+
+// vaultCommand runs one vault subcommand. Every change is committed
+// at the end only, a failure leaves the vault as it was.
+func vaultCommand(md *cliarg.ArgsMetaData, creds *credentials) []result.Result {
+	name := md.FileNames[0]
+	return []result.Result{result.New(name, runVault(name, md, creds))}
+}
+
+func runVault(name string, md *cliarg.ArgsMetaData, creds *credentials) error {
+	if md.VaultAction == cliarg.VaultCreate {
+		if err := createVault(name, creds); err != nil {
+			return err
+		}
+		fmt.Println(esccode.Green, name, "created", esccode.Reset)
+		return nil
+	}
+
+	hdr, dataKey, _, err := openHeader(name, creds)
+	if err != nil {
+		return err
+	}
+	defer dataKey.Destroy()
+	v, err := vault.Open(name, hdr, dataKey)
+	if err != nil {
+		return err
+	}
+	defer v.Close()
+
+	switch md.VaultAction {
+	case cliarg.VaultAdd:
+		err = vaultAdd(v, md)
+	case cliarg.VaultList:
+		vaultList(v)
+	case cliarg.VaultExtract:
+		err = vaultExtract(v, md)
+	case cliarg.VaultRemove:
+		err = vaultRemove(v, md.VaultArgs)
+	case cliarg.VaultCompact:
+		garbage := v.Garbage()
+		if err = v.Compact(); err == nil {
+			fmt.Printf("%s %s compacted, %d bytes freed%s\n", esccode.Green, name, garbage, esccode.Reset)
+		}
+	}
+	return err
+}
+
+func createVault(name string, creds *credentials) error {
+	salt, err := salting.NewRandomSalt(saltSize)
+	if err != nil {
+		return err
+	}
+	nonce, err := salting.NewRandomNonce(nonceSize)
+	if err != nil {
+		return err
+	}
+	hdr, dataKey, err := newHeader(creds, salt, nonce)
+	if err != nil {
+		return err
+	}
+	defer dataKey.Destroy()
+
+	v, err := vault.Create(name, hdr, dataKey)
+	if err != nil {
+		return err
+	}
+	return v.Close()
+}
+
+func vaultAdd(v *vault.Vault, md *cliarg.ArgsMetaData) error {
+	entries, err := walk.Files(md.VaultArgs, md.Walk, nil)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if err := vaultAddFile(v, entry); err != nil {
+			v.Rollback()
+			return &os.PathError{Op: "vault add", Path: entry.Path, Err: err}
+		}
+	}
+	if err := v.Commit(); err != nil {
+		return err
+	}
+	fmt.Printf("%s %d files added%s\n", esccode.Green, len(entries), esccode.Reset)
+	return nil
+}
+
+func vaultAddFile(v *vault.Vault, entry walk.Entry) error {
+	file, err := os.Open(entry.Path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	return v.Add(filepath.ToSlash(entry.Rel), file, info)
+}
+
+func vaultList(v *vault.Vault) {
+	for _, entry := range v.Entries() {
+		fmt.Printf("%s\t%10d  %s  %s\n", entry.Mode, entry.Size, entry.ModTime.Format("2006-01-02 15:04"), entry.Name)
+	}
+	fmt.Printf("%s%d entries, %d bytes to reclaim with compact%s\n", esccode.Cyan, len(v.Entries()), v.Garbage(), esccode.Reset)
+}
+
+// vaultExtract writes the named entries, or all of them, under the
+// output root. Names are checked like archive entries, so a vault
+// can't write outside of the output root.
+func vaultExtract(v *vault.Vault, md *cliarg.ArgsMetaData) error {
+	root := md.OutputDir
+	if root == "" {
+		root = "."
+	}
+	names := md.VaultArgs
+	if len(names) == 0 {
+		for _, entry := range v.Entries() {
+			names = append(names, entry.Name)
+		}
+	}
+
+	for _, name := range names {
+		target, err := archive.EntryPath(root, name)
+		if err != nil {
+			return err
+		}
+		if err := extractEntry(v, name, target); err != nil {
+			return &os.PathError{Op: "vault extract", Path: name, Err: err}
+		}
+		fmt.Println(esccode.Green, target, esccode.Reset)
+	}
+	return nil
+}
+
+func extractEntry(v *vault.Vault, name, target string) error {
+	if err := os.MkdirAll(filepath.Dir(target), 0700); err != nil {
+		return err
+	}
+	mode := os.FileMode(0600)
+	for _, entry := range v.Entries() {
+		if entry.Name == name {
+			mode = entry.Mode
+		}
+	}
+	file, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_EXCL, mode)
+	if err != nil {
+		return err
+	}
+	err = v.Extract(name, file)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(target)
+	}
+	return err
+}
+
+// vaultRemove drops the entries and compacts the vault right away,
+// so the removed payloads don't stay in the file.
+func vaultRemove(v *vault.Vault, names []string) error {
+	for _, name := range names {
+		if err := v.Remove(name); err != nil {
+			return err
+		}
+	}
+	if err := v.Compact(); err != nil {
+		return err
+	}
+	fmt.Printf("%s %d entries removed%s\n", esccode.Green, len(names), esccode.Reset)
+	return nil
+}
@@ -0,0 +1,97 @@
+package archivetest
+
+import (
+	"archive/tar"
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	archive "github.com/ShuaibKhan786/cipher-project/internal/archive"
+	walk "github.com/ShuaibKhan786/cipher-project/internal/walk"
+)
+
+func TestArchive(t *testing.T) {
+	t.Run("testing a pack and unpack round trip", func(t *testing.T) {
+		src := t.TempDir()
+		files := map[string]string{"a.txt": "first", "sub/b.txt": "second"}
+		for name, content := range files {
+			path := filepath.Join(src, name)
+			os.MkdirAll(filepath.Dir(path), 0700)
+			if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+				t.Fatal(err)
+			}
+		}
+		entries, err := walk.Files([]string{src}, walk.DefaultPolicy, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var packed bytes.Buffer
+		if err := archive.Pack(&packed, entries); err != nil {
+			t.Fatal(err)
+		}
+		dst := t.TempDir()
+		count, err := archive.Unpack(&packed, dst)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if count != len(files) {
+			t.Errorf("got : %v files want : %v", count, len(files))
+		}
+		for name, content := range files {
+			got, err := os.ReadFile(filepath.Join(dst, filepath.Base(src), name))
+			if err != nil || string(got) != content {
+				t.Errorf("%s got : %q want : %q", name, got, content)
+			}
+		}
+	})
+
+	t.Run("testing unsafe entry names", func(t *testing.T) {
+		for _, name := range []string{"../evil", "a/../../evil", "/etc/evil", `..\evil`, `C:\evil`, `\evil`} {
+			dst := t.TempDir()
+			if _, err := archive.Unpack(tarWith(t, name), dst); err == nil {
+				t.Errorf("%q must be refused", name)
+			}
+		}
+	})
+
+	t.Run("testing a symlink in the output directory", func(t *testing.T) {
+		outside := t.TempDir()
+		for _, name := range []string{"link/evil", "link"} {
+			dst := t.TempDir()
+			if err := os.Symlink(outside, filepath.Join(dst, "link")); err != nil {
+				t.Skip(err)
+			}
+			_, err := archive.Unpack(tarWith(t, name), dst)
+			if err == nil || !strings.Contains(err.Error(), archive.SymlinkEntryErr) {
+				t.Errorf("%q got : %v want : %v", name, err, archive.SymlinkEntryErr)
+			}
+		}
+		if entries, _ := os.ReadDir(outside); len(entries) != 0 {
+			t.Errorf("nothing may be written through the symlink, got : %v", entries)
+		}
+	})
+
+	t.Run("testing safe entry names", func(t *testing.T) {
+		for _, name := range []string{"a", "a/b", "./a", "a/../b"} {
+			if _, err := archive.EntryPath("root", name); err != nil {
+				t.Errorf("%q got : %v want : no error", name, err)
+			}
+		}
+	})
+}
+
+func tarWith(t *testing.T, name string) *bytes.Buffer {
+	t.Helper()
+	var buffer bytes.Buffer
+	tw := tar.NewWriter(&buffer)
+	content := []byte("evil")
+	if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0600, Size: int64(len(content)), Typeflag: tar.TypeReg}); err != nil {
+		t.Fatal(err)
+	}
+	tw.Write(content)
+	tw.Close()
+	return &buffer
+}
@@ -1,21 +1,27 @@
 package cipher_test
 
 import (
+	"bytes"
+	"errors"
 	"io"
 	"log"
 	"os"
+	"strings"
 	"testing"
 
 	cipher "github.com/ShuaibKhan786/cipher-project/internal/cipher"
 	cliarg "github.com/ShuaibKhan786/cipher-project/internal/cmdlineargs"
+	header "github.com/ShuaibKhan786/cipher-project/internal/header"
+	kdf "github.com/ShuaibKhan786/cipher-project/internal/kdf"
 	salting "github.com/ShuaibKhan786/cipher-project/internal/salting"
+	secbuf "github.com/ShuaibKhan786/cipher-project/internal/secbuf"
 )
 
 func TestCipher(t *testing.T) {
 	md := cliarg.ArgsMetaData{
-		FileNames: []string{"file1.txt", "file2.txt", "file3.txt"},
+		FileNames:  []string{"file1.txt", "file2.txt", "file3.txt"},
 		NumOfFiles: 3,
-		Operation: "-e",
+		Operation:  "-e",
 	}
 	gtracker := cipher.InitGlobalProgressTracker(md.FileNames)
 
@@ -26,12 +32,7 @@ func TestCipher(t *testing.T) {
 	t.Run("testing file encryption in a cipher", func(t *testing.T) {
 		data := "For testing purpose"
 
-		mdEnc := cipher.EncryptionMetadata{
-			Filename: md.FileNames[0],
-			Key:      []byte("E4A18C31B5D4923C57A9E4AB96FCA12A"),
-			Nonce:    salting.Nonce("6A8B1D4E372A"),
-			Salt:     salting.Salt("9DFA18BB1E473CD9"),
-		}
+		mdEnc := newEncryptionMetadata(t, md.FileNames[0])
 
 		if err := tempOpenWrite(mdEnc.Filename, data); err != nil {
 			log.Fatal(err)
@@ -42,7 +43,7 @@ func TestCipher(t *testing.T) {
 
 		go func() {
 			for range testChannel {
-				
+
 			}
 		}()
 
@@ -57,6 +58,117 @@ func TestCipher(t *testing.T) {
 		os.Remove(mdEnc.Filename)
 		os.Remove(mdEnc.Filename + cliarg.EncryptedFileExt)
 	})
+
+	t.Run("testing file decryption after encryption", func(t *testing.T) {
+		data := strings.Repeat("For testing purpose", cipher.ChunkSize/10)
+
+		mdEnc := newEncryptionMetadata(t, md.FileNames[1])
+		if err := tempOpenWrite(mdEnc.Filename, data); err != nil {
+			log.Fatal(err)
+		}
+		defer os.Remove(mdEnc.Filename)
+		defer os.Remove(mdEnc.Filename + cliarg.EncryptedFileExt)
+
+		testChannel := make(chan cipher.CipherProgress)
+		defer close(testChannel)
+
+		go func() {
+			for range testChannel {
+
+			}
+		}()
+
+		err := cipher.Encryption(mdEnc, testChannel, gtracker)
+		assertError(mdEnc.Filename, err, t)
+		os.Remove(mdEnc.Filename)
+
+		hdr, err := header.ReadFile(mdEnc.Filename + cliarg.EncryptedFileExt)
+		assertError(mdEnc.Filename, err, t)
+
+		mdDec := cipher.DecryptionMetadata{
+			Filename: mdEnc.Filename + cliarg.EncryptedFileExt,
+			Key:      mdEnc.Key,
+			Nonce:    hdr.Nonce,
+			SeekSize: hdr.Size(),
+		}
+		err = cipher.Decryption(mdDec, testChannel, gtracker)
+		assertError(mdEnc.Filename, err, t)
+
+		plainText, err := tempOpenRead(mdEnc.Filename)
+		assertError(mdEnc.Filename, err, t)
+
+		if string(plainText) != data {
+			t.Errorf("decrypted content doesn't match the original")
+		}
+	})
+
+	t.Run("testing decryption over an existing file", func(t *testing.T) {
+		data := "For testing purpose"
+		mdEnc := newEncryptionMetadata(t, md.FileNames[2])
+		if err := tempOpenWrite(mdEnc.Filename, data); err != nil {
+			log.Fatal(err)
+		}
+		defer os.Remove(mdEnc.Filename)
+		defer os.Remove(mdEnc.Filename + cliarg.EncryptedFileExt)
+
+		testChannel := make(chan cipher.CipherProgress)
+		defer close(testChannel)
+		go func() {
+			for range testChannel {
+			}
+		}()
+
+		err := cipher.Encryption(mdEnc, testChannel, gtracker)
+		assertError(mdEnc.Filename, err, t)
+		if err := tempOpenWrite(mdEnc.Filename, "kept"); err != nil {
+			log.Fatal(err)
+		}
+
+		hdr, err := header.ReadFile(mdEnc.Filename + cliarg.EncryptedFileExt)
+		assertError(mdEnc.Filename, err, t)
+		mdDec := cipher.DecryptionMetadata{
+			Filename: mdEnc.Filename + cliarg.EncryptedFileExt,
+			Key:      mdEnc.Key,
+			Nonce:    hdr.Nonce,
+			SeekSize: hdr.Size(),
+		}
+		err = cipher.Decryption(mdDec, testChannel, gtracker)
+		if !errors.Is(err, cipher.ErrOutputExists) {
+			t.Errorf("got : %v want : %v", err, cipher.ErrOutputExists)
+		}
+		if got, _ := tempOpenRead(mdEnc.Filename); string(got) != "kept" {
+			t.Errorf("an existing file must be left as it was, got : %q", got)
+		}
+
+		mdDec.Force = true
+		err = cipher.Decryption(mdDec, testChannel, gtracker)
+		assertError(mdEnc.Filename, err, t)
+		if got, _ := tempOpenRead(mdEnc.Filename); string(got) != data {
+			t.Errorf("got : %q want : %q", got, data)
+		}
+	})
+}
+
+func newEncryptionMetadata(t *testing.T, filename string) cipher.EncryptionMetadata {
+	t.Helper()
+
+	key := []byte("E4A18C31B5D4923C57A9E4AB96FCA12A")
+	dataKey, err := header.NewDataKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	slot, err := header.NewPasswordSlot(dataKey, key, salting.Salt("9DFA18BB1E473CD9"), kdf.DefaultParams)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hdr := header.New(salting.Nonce("6A8B1D4E372A"))
+	hdr.AddSlot(slot)
+
+	return cipher.EncryptionMetadata{
+		Filename: filename,
+		Key:      secbuf.From(dataKey),
+		Header:   hdr,
+	}
 }
 
 func assertFalseNil(t *testing.T, gtracker *cipher.GlobalProgressTracker) {
@@ -106,26 +218,23 @@ func assertError(filename string, err error, t *testing.T) {
 func assertEncryption(cipherText []byte, want *cipher.EncryptionMetadata, t *testing.T) {
 	t.Helper()
 
-	saltLength := len(want.Salt)
-	nonceLength := len(want.Nonce)
-	if len(cipherText) < saltLength+nonceLength {
-		t.Errorf("Cipher text length is too short to extract salt and nonce")
+	got, err := header.Read(bytes.NewReader(cipherText))
+	if err != nil {
+		t.Errorf("Cipher text doesn't start with a valid header: %s", err.Error())
 		return
 	}
 
-	got := cipher.EncryptionMetadata{
-		Filename: want.Filename,
-		Key:      want.Key,
-		Salt:     salting.Salt(cipherText[:saltLength]),
-		Nonce:    salting.Nonce(cipherText[saltLength : saltLength+nonceLength]),
+	if !checkEqual(got.Nonce, want.Header.Nonce) {
+		t.Errorf("NONCE:\n\tgot : %s\n\twant: %s", got.Nonce, want.Header.Nonce)
 	}
 
-	if !checkEqual(got.Nonce, want.Nonce) {
-		t.Errorf("NONCE:\n\tgot : %s\n\twant: %s", got.Nonce, want.Nonce)
+	gotSlot, err := got.PasswordSlot()
+	if err != nil {
+		t.Errorf("Expected a password slot in the header")
+		return
 	}
-
-	if !checkEqual(got.Salt, want.Salt) {
-		t.Errorf("SALT:\n\tgot : %s\n\twant: %s", got.Salt, want.Salt)
+	if !checkEqual(gotSlot.Salt, want.Header.Slots[0].Salt) {
+		t.Errorf("SALT:\n\tgot : %s\n\twant: %s", gotSlot.Salt, want.Header.Slots[0].Salt)
 	}
 }
 
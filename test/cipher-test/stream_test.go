@@ -0,0 +1,127 @@
+package cipher_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+
+	cipher "github.com/ShuaibKhan786/cipher-project/internal/cipher"
+	header "github.com/ShuaibKhan786/cipher-project/internal/header"
+)
+
+func TestStream(t *testing.T) {
+	t.Run("testing a stream round trip at chunk boundaries", func(t *testing.T) {
+		for _, size := range []int{0, 10, cipher.ChunkSize, cipher.ChunkSize + 1, 2 * cipher.ChunkSize} {
+			md := newEncryptionMetadata(t, "stream")
+			data := bytes.Repeat([]byte{'s'}, size)
+
+			sealed := seal(t, md, data)
+			got, err := io.ReadAll(open(t, md, sealed))
+			if err != nil {
+				t.Fatalf("%d bytes: %s", size, err.Error())
+			}
+			if !bytes.Equal(got, data) {
+				t.Errorf("%d bytes: the decrypted stream doesn't match the original", size)
+			}
+		}
+	})
+
+	t.Run("testing a truncated stream", func(t *testing.T) {
+		md := newEncryptionMetadata(t, "stream")
+		sealed := seal(t, md, bytes.Repeat([]byte{'s'}, 2*cipher.ChunkSize+5))
+
+		// dropping the final chunk leaves whole chunks only
+		hdrSize := int(md.Header.Size())
+		chunk := cipher.ChunkSize + 16
+		if _, err := io.ReadAll(open(t, md, sealed[:hdrSize+2*chunk])); !errors.Is(err, cipher.ErrTruncated) {
+			t.Errorf("got : %v want : %v", err, cipher.ErrTruncated)
+		}
+	})
+
+	t.Run("testing a stream cut inside a chunk", func(t *testing.T) {
+		md := newEncryptionMetadata(t, "stream")
+		sealed := seal(t, md, bytes.Repeat([]byte{'s'}, 2*cipher.ChunkSize+5))
+
+		hdrSize := int(md.Header.Size())
+		chunk := cipher.ChunkSize + 16
+		_, err := io.ReadAll(open(t, md, sealed[:hdrSize+chunk+chunk/2]))
+		var chunkErr *cipher.ChunkError
+		if !errors.As(err, &chunkErr) || !errors.Is(err, cipher.ErrTruncated) {
+			t.Fatalf("got : %v want : %v", err, cipher.ErrTruncated)
+		}
+		if chunkErr.Chunk != 1 {
+			t.Errorf("got : chunk %d want : chunk %d", chunkErr.Chunk, 1)
+		}
+	})
+
+	t.Run("testing a modified stream", func(t *testing.T) {
+		md := newEncryptionMetadata(t, "stream")
+		sealed := seal(t, md, bytes.Repeat([]byte{'s'}, 2*cipher.ChunkSize+5))
+
+		// a full chunk keeps its length, only its bytes changed
+		hdrSize := int(md.Header.Size())
+		chunk := cipher.ChunkSize + 16
+		sealed[hdrSize+2*chunk-1] ^= 1
+		_, err := io.ReadAll(open(t, md, sealed))
+		var chunkErr *cipher.ChunkError
+		if !errors.As(err, &chunkErr) || !errors.Is(err, cipher.ErrAuthentication) {
+			t.Fatalf("got : %v want : %v", err, cipher.ErrAuthentication)
+		}
+		if chunkErr.Chunk != 1 {
+			t.Errorf("got : chunk %d want : chunk %d", chunkErr.Chunk, 1)
+		}
+	})
+
+	t.Run("testing a modified stream of known length", func(t *testing.T) {
+		md := newEncryptionMetadata(t, "stream")
+		sealed := seal(t, md, bytes.Repeat([]byte{'s'}, cipher.ChunkSize+5))
+
+		// the short final chunk can't have been cut, so it was changed
+		sealed[len(sealed)-1] ^= 1
+		hdrSize := md.Header.Size()
+		section := io.NewSectionReader(bytes.NewReader(sealed), hdrSize, int64(len(sealed))-hdrSize)
+		reader, err := cipher.NewSectionReader(section, md.Key, md.Header.Nonce)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := io.ReadAll(reader); !errors.Is(err, cipher.ErrAuthentication) {
+			t.Errorf("got : %v want : %v", err, cipher.ErrAuthentication)
+		}
+	})
+}
+
+func seal(t *testing.T, md cipher.EncryptionMetadata, data []byte) []byte {
+	t.Helper()
+	var sealed bytes.Buffer
+	w, err := cipher.NewWriter(&sealed, md.Key, md.Header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// odd sized writes cross the chunk boundaries
+	for len(data) > 0 {
+		n := min(len(data), 7777)
+		if _, err := w.Write(data[:n]); err != nil {
+			t.Fatal(err)
+		}
+		data = data[n:]
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return sealed.Bytes()
+}
+
+func open(t *testing.T, md cipher.EncryptionMetadata, sealed []byte) io.Reader {
+	t.Helper()
+	r := bytes.NewReader(sealed)
+	hdr, err := header.Read(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reader, err := cipher.NewReader(r, md.Key, hdr.Nonce)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return reader
+}
@@ -2,15 +2,17 @@ package cmdlineargstest
 
 import (
 	"os"
+	"path/filepath"
 	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/ShuaibKhan786/cipher-project/internal/cmdlineargs"
 )
 
 func TestCmdlineArgs(t *testing.T) {
-	t.Run("testing with enough args",func(t *testing.T) {
-		os.Args = []string {
+	t.Run("testing with enough args", func(t *testing.T) {
+		os.Args = []string{
 			"processName",
 			"operation",
 			"file1",
@@ -21,11 +23,11 @@ func TestCmdlineArgs(t *testing.T) {
 		got := cmdlineargs.NewArgsMetaData()
 		want := os.Args
 
-		checkAssertions(got,want,t)
+		checkAssertions(got, want, t)
 	})
 
-	t.Run("testing with less args",func(t *testing.T) {
-		os.Args = []string {
+	t.Run("testing with less args", func(t *testing.T) {
+		os.Args = []string{
 			"processName",
 		}
 
@@ -33,43 +35,239 @@ func TestCmdlineArgs(t *testing.T) {
 
 		if len(got.FileNames) != 0 && len(got.Operation) != 0 {
 			t.Errorf("must not get anything")
-		} 
+		}
+	})
+
+	t.Run("testing a file list", func(t *testing.T) {
+		list := filepath.Join(t.TempDir(), "list")
+		if err := os.WriteFile(list, []byte("file2\x00\x00file3\x00"), 0600); err != nil {
+			t.Fatal(err)
+		}
+		os.Args = []string{
+			"processName",
+			"operation",
+			"file1",
+			cmdlineargs.FilesFromOpt,
+			list,
+			cmdlineargs.NullSeparatorOpt,
+		}
+
+		got := cmdlineargs.NewArgsMetaData()
+		want := []string{"processName", "operation", "file1", "file2", "file3"}
+
+		checkAssertions(got, want, t)
+	})
+
+	t.Run("testing the number of jobs", func(t *testing.T) {
+		os.Args = []string{
+			"processName",
+			"operation",
+			cmdlineargs.JobsOpt,
+			"3",
+			"file1",
+		}
+
+		got := cmdlineargs.NewArgsMetaData()
+		if got.Jobs != 3 {
+			t.Errorf("got : %v want : %v", got.Jobs, 3)
+		}
+		checkAssertions(got, []string{"processName", "operation", "file1"}, t)
+	})
+
+	t.Run("testing the shred passes", func(t *testing.T) {
+		os.Args = []string{
+			"processName",
+			cmdlineargs.EncryptionOp,
+			cmdlineargs.ShredOpt,
+			cmdlineargs.PassesOpt,
+			"5",
+			"file1",
+		}
+
+		got := cmdlineargs.NewArgsMetaData()
+		if !got.Shred || got.Passes != 5 {
+			t.Errorf("got : %v %v want : %v %v", got.Shred, got.Passes, true, 5)
+		}
+
+		os.Args = []string{
+			"processName",
+			cmdlineargs.EncryptionOp,
+			cmdlineargs.PassesOpt,
+			"5",
+			"file1",
+		}
+		md := cmdlineargs.NewArgsMetaData()
+		state, err := md.IsValid()
+		if state || !strings.Contains(err.Error(), cmdlineargs.PassesOptErr) {
+			t.Errorf("got : %v want : %v", err, cmdlineargs.PassesOptErr)
+		}
+	})
+
+	t.Run("testing the removal of the originals", func(t *testing.T) {
+		tests := map[string][]string{
+			cmdlineargs.OriginalsOptErr: {cmdlineargs.DeleteOriginalsOpt, cmdlineargs.KeepOriginalsOpt},
+			cmdlineargs.ShredKeepErr:    {cmdlineargs.ShredOpt, cmdlineargs.KeepOriginalsOpt},
+		}
+		for want, options := range tests {
+			os.Args = append([]string{"processName", cmdlineargs.EncryptionOp}, append(options, "file1")...)
+
+			md := cmdlineargs.NewArgsMetaData()
+			state, err := md.IsValid()
+			if state || !strings.Contains(err.Error(), want) {
+				t.Errorf("%v: got : %v want : %v", options, err, want)
+			}
+		}
+	})
+
+	t.Run("testing a command line without files or a known command", func(t *testing.T) {
+		tests := map[string][]string{
+			cmdlineargs.UnknownCommandErr + "foo":             {"foo"},
+			cmdlineargs.NoFilesErr + cmdlineargs.EncryptionOp: {cmdlineargs.EncryptionOp},
+			cmdlineargs.PasswordOptErr:                        {cmdlineargs.EncryptionOp, "file1", cmdlineargs.PasswordOpt},
+		}
+		for want, args := range tests {
+			os.Args = append([]string{"processName"}, args...)
+
+			md := cmdlineargs.NewArgsMetaData()
+			state, err := md.IsValid()
+			// only a command line without a command shows the overview
+			if md.Operation == "" {
+				t.Errorf("%v: the command must be kept", args)
+			}
+			if state || !strings.Contains(err.Error(), want) {
+				t.Errorf("%v: got : %v want : %v", args, err, want)
+			}
+		}
+	})
+
+	t.Run("testing a command alias", func(t *testing.T) {
+		os.Args = []string{
+			"processName",
+			"-d",
+			"f.enc",
+		}
+
+		got := cmdlineargs.NewArgsMetaData()
+		checkAssertions(got, []string{"processName", cmdlineargs.DecryptionOp, "f.enc"}, t)
+	})
+
+	t.Run("testing options the command doesn't take", func(t *testing.T) {
+		tests := map[string]string{
+			"--bogus":                cmdlineargs.InvalidOptionErr,
+			cmdlineargs.RecipientOpt: cmdlineargs.UnsupportedOptionErr,
+		}
+		for option, want := range tests {
+			os.Args = []string{
+				"processName",
+				cmdlineargs.DecryptionOp,
+				option,
+				"key.pub",
+				"f.enc",
+			}
+
+			md := cmdlineargs.NewArgsMetaData()
+			state, err := md.IsValid()
+			if state || !strings.Contains(err.Error(), want+option) {
+				t.Errorf("%v: got : %v want : %v", option, err, want+option)
+			}
+		}
+	})
+
+	t.Run("testing a short filename to decrypt", func(t *testing.T) {
+		wd, err := os.Getwd()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chdir(t.TempDir()); err != nil {
+			t.Fatal(err)
+		}
+		defer os.Chdir(wd)
+		if err := os.WriteFile("a", nil, 0600); err != nil {
+			t.Fatal(err)
+		}
+		os.Args = []string{
+			"processName",
+			cmdlineargs.DecryptionOp,
+			"a",
+		}
+
+		md := cmdlineargs.NewArgsMetaData()
+		state, err := md.IsValid()
+		if state || !strings.Contains(err.Error(), cmdlineargs.InvalidDeExtErr) {
+			t.Errorf("got : %v want : %v", err, cmdlineargs.InvalidDeExtErr)
+		}
+	})
+
+	t.Run("testing outputs colliding in the output directory", func(t *testing.T) {
+		wd, err := os.Getwd()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chdir(t.TempDir()); err != nil {
+			t.Fatal(err)
+		}
+		defer os.Chdir(wd)
+		for _, name := range []string{"a", "b"} {
+			if err := os.Mkdir(name, 0700); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(filepath.Join(name, "same.txt"), nil, 0600); err != nil {
+				t.Fatal(err)
+			}
+		}
+		a, b, out := filepath.Join("a", "same.txt"), filepath.Join("b", "same.txt"), "out"
+
+		os.Args = []string{"processName", cmdlineargs.EncryptionOp, cmdlineargs.OutputOpt, out, a, b}
+		md := cmdlineargs.NewArgsMetaData()
+		state, err := md.IsValid()
+		if state || !strings.Contains(err.Error(), cmdlineargs.OutputCollisionErr) {
+			t.Errorf("got : %v want : %v", err, cmdlineargs.OutputCollisionErr)
+		}
+
+		os.Args = []string{"processName", cmdlineargs.EncryptionOp, cmdlineargs.OutputRootOpt, out, a, b}
+		md = cmdlineargs.NewArgsMetaData()
+		if state, err := md.IsValid(); !state {
+			t.Fatalf("expected no error, but got %v", err)
+		}
+		if got := md.Output(a); got != filepath.Join(out, "a", "same.txt.enc") {
+			t.Errorf("got : %v want : %v", got, filepath.Join(out, "a", "same.txt.enc"))
+		}
 	})
 
 	t.Run("testing the file existance and valid operation", func(t *testing.T) {
-		os.Args = []string {
+		os.Args = []string{
 			"processName",
 			"-e",
 			"file1.txt",
 		}
 
 		md := cmdlineargs.NewArgsMetaData()
-		state,err := md.IsValid()
+		state, err := md.IsValid()
 
 		if state {
 			t.Errorf("must return false")
 		}
 		if err.Error() != cmdlineargs.InvalidFilenamesErr {
-			t.Errorf("must return this error : %v",cmdlineargs.InvalidFilenamesErr)
+			t.Errorf("must return this error : %v", cmdlineargs.InvalidFilenamesErr)
 		}
 	})
 }
 
-func checkAssertions(got cmdlineargs.ArgsMetaData, want []string,t testing.TB) {
+func checkAssertions(got cmdlineargs.ArgsMetaData, want []string, t testing.TB) {
 	t.Helper()
 
 	fileNames := want[2:]
 	operation := want[1]
 
-	if !reflect.DeepEqual(fileNames,got.FileNames) {
-		t.Errorf("got : %v and want %v",got.FileNames,fileNames)
-	} 
+	if !reflect.DeepEqual(fileNames, got.FileNames) {
+		t.Errorf("got : %v and want %v", got.FileNames, fileNames)
+	}
 
 	if operation != got.Operation {
-		t.Errorf("got : %v and want %v",got.FileNames,fileNames)
+		t.Errorf("got : %v and want %v", got.FileNames, fileNames)
 	}
 
 	if got.NumOfFiles != len(fileNames) {
-		t.Errorf("got : %v and want %v",got.NumOfFiles,len(fileNames))
+		t.Errorf("got : %v and want %v", got.NumOfFiles, len(fileNames))
 	}
-}
\ No newline at end of file
+}
@@ -0,0 +1,138 @@
+package configtest
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	cliarg "github.com/ShuaibKhan786/cipher-project/internal/cmdlineargs"
+	config "github.com/ShuaibKhan786/cipher-project/internal/config"
+)
+
+func TestConfig(t *testing.T) {
+	dir := t.TempDir()
+	user := filepath.Join(dir, "xdg", config.DirName, config.FileName)
+	if err := os.MkdirAll(filepath.Dir(user), 0700); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, user, "# defaults\njobs = 2\n\n[encrypt]\noutput-dir = \"out dir\"\n[decrypt]\njobs = 4\n")
+
+	t.Run("testing the settings of a command", func(t *testing.T) {
+		settings, err := config.ReadFile(user)
+		assertError(t, err)
+
+		c := &config.Config{Settings: settings}
+		want := []config.Setting{
+			{Key: "jobs", Value: "2", Source: user + ":2"},
+			{Key: "output-dir", Value: "out dir", Section: cliarg.EncryptionOp, Source: user + ":5"},
+		}
+		if got := c.For(cliarg.EncryptionOp); !reflect.DeepEqual(got, want) {
+			t.Errorf("got : %v want : %v", got, want)
+		}
+	})
+
+	t.Run("testing a malformed line", func(t *testing.T) {
+		bad := filepath.Join(dir, "bad")
+		writeFile(t, bad, "jobs = 2\njobs\n")
+
+		_, err := config.ReadFile(bad)
+		if err == nil || !strings.Contains(err.Error(), bad+":2") {
+			t.Errorf("got : %v want an error at %v", err, bad+":2")
+		}
+	})
+
+	t.Run("testing the command line over the project file over the user file", func(t *testing.T) {
+		t.Setenv("XDG_CONFIG_HOME", filepath.Join(dir, "xdg"))
+		wd, err := os.Getwd()
+		assertError(t, err)
+		assertError(t, os.Chdir(dir))
+		defer os.Chdir(wd)
+		writeFile(t, config.ProjectFile, "[encrypt]\nhidden = skip\nwords = 8\n")
+		defer os.Remove(config.ProjectFile)
+
+		os.Args = []string{"processName", cliarg.EncryptionOp, cliarg.WordsOpt, "9", "file"}
+		md := cliarg.NewArgsMetaData()
+		if md.Jobs != 2 || md.OutputDir != "out dir" || md.Walk.Hidden != "skip" || md.Words != 9 {
+			t.Errorf("got : jobs %v, output dir %v, hidden %v, words %v want : 2, out dir, skip, 9", md.Jobs, md.OutputDir, md.Walk.Hidden, md.Words)
+		}
+
+		os.Args = []string{"processName", cliarg.ConfigOp, cliarg.ConfigShow, cliarg.EncryptionOp}
+		md = cliarg.NewArgsMetaData()
+		for _, s := range md.Settings() {
+			if s.Option == cliarg.HiddenOpt && s.Source != config.ProjectFile+":2" {
+				t.Errorf("got : %v want : %v", s.Source, config.ProjectFile+":2")
+			}
+		}
+	})
+
+	t.Run("testing the removal of the originals from the user file", func(t *testing.T) {
+		home := filepath.Join(dir, "removal")
+		t.Setenv("XDG_CONFIG_HOME", home)
+		file := filepath.Join(home, config.DirName, config.FileName)
+		assertError(t, os.MkdirAll(filepath.Dir(file), 0700))
+		writeFile(t, file, "[encrypt]\ndelete-originals = true\n")
+
+		os.Args = []string{"processName", cliarg.EncryptionOp, "file"}
+		md := cliarg.NewArgsMetaData()
+		if !md.DeleteOriginals || md.KeepOriginals {
+			t.Errorf("got : delete %v, keep %v want : true, false", md.DeleteOriginals, md.KeepOriginals)
+		}
+
+		// the command line wins over the file, the two don't clash
+		os.Args = []string{"processName", cliarg.EncryptionOp, cliarg.KeepOriginalsOpt, "file"}
+		md = cliarg.NewArgsMetaData()
+		if md.DeleteOriginals || !md.KeepOriginals {
+			t.Errorf("got : delete %v, keep %v want : false, true", md.DeleteOriginals, md.KeepOriginals)
+		}
+
+		os.Args = []string{"processName", cliarg.ConfigOp, cliarg.ConfigShow, cliarg.EncryptionOp}
+		md = cliarg.NewArgsMetaData()
+		found := false
+		for _, s := range md.Settings() {
+			if s.Option == cliarg.DeleteOriginalsOpt {
+				found = true
+				if s.Value != "true" || s.Source != file+":2" {
+					t.Errorf("got : %v from %v want : true from %v", s.Value, s.Source, file+":2")
+				}
+			}
+		}
+		if !found {
+			t.Errorf("config show must list %v", cliarg.DeleteOriginalsOpt)
+		}
+	})
+
+	t.Run("testing the settings the project file can't change", func(t *testing.T) {
+		t.Setenv("XDG_CONFIG_HOME", filepath.Join(dir, "xdg"))
+		wd, err := os.Getwd()
+		assertError(t, err)
+		assertError(t, os.Chdir(dir))
+		defer os.Chdir(wd)
+		defer os.Remove(config.ProjectFile)
+
+		for _, key := range []string{"min-score = 0", "output-dir = /tmp/elsewhere", "include = *", "exclude = *", "symlinks = follow", "shred = true", "delete-originals = true", "keep-originals = true"} {
+			writeFile(t, config.ProjectFile, key+"\n")
+			os.Args = []string{"processName", cliarg.EncryptionOp, "file"}
+			md := cliarg.NewArgsMetaData()
+			state, err := md.IsValid()
+			if state || !strings.Contains(err.Error(), cliarg.ConfigUserErr) {
+				t.Errorf("%v: got : %v want : %v", key, err, cliarg.ConfigUserErr)
+			}
+		}
+	})
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func assertError(t *testing.T, err error) {
+	t.Helper()
+	if err != nil {
+		t.Errorf("expected no error, but got %v", err)
+	}
+}
@@ -0,0 +1,45 @@
+package escapecodetest
+
+import (
+	"testing"
+
+	esccode "github.com/ShuaibKhan786/cipher-project/internal/escapecode"
+)
+
+func TestSetMode(t *testing.T) {
+	defer esccode.SetMode(esccode.ColorAuto)
+
+	esccode.SetMode(esccode.ColorAlways)
+	if esccode.Red == "" || esccode.Reset == "" {
+		t.Fatalf("always: colors are off")
+	}
+	if got := esccode.Stderr(esccode.Red + "x" + esccode.Reset); got != esccode.Red+"x"+esccode.Reset {
+		t.Errorf("always: Stderr = %q", got)
+	}
+
+	esccode.SetMode(esccode.ColorNever)
+	if esccode.Red != "" || esccode.Reset != "" || esccode.Green != "" {
+		t.Errorf("never: colors are on")
+	}
+
+	// go test runs without a terminal, and NO_COLOR wins over one
+	t.Setenv("NO_COLOR", "1")
+	esccode.SetMode(esccode.ColorAuto)
+	if esccode.Red != "" {
+		t.Errorf("auto with NO_COLOR: colors are on")
+	}
+	if got := esccode.Stderr("\033[31mx\033[0m"); got != "x" {
+		t.Errorf("auto with NO_COLOR: Stderr = %q", got)
+	}
+}
+
+func TestValidMode(t *testing.T) {
+	for _, mode := range []string{"", "auto", "always", "never"} {
+		if !esccode.ValidMode(mode) {
+			t.Errorf("ValidMode(%q) = false", mode)
+		}
+	}
+	if esccode.ValidMode("yes") {
+		t.Errorf("ValidMode(\"yes\") = true")
+	}
+}
@@ -0,0 +1,57 @@
+package eventtest
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	esccode "github.com/ShuaibKhan786/cipher-project/internal/escapecode"
+	event "github.com/ShuaibKhan786/cipher-project/internal/event"
+	header "github.com/ShuaibKhan786/cipher-project/internal/header"
+	result "github.com/ShuaibKhan786/cipher-project/internal/result"
+)
+
+func TestEmitter(t *testing.T) {
+	var buffer bytes.Buffer
+	events := event.NewEmitter(&buffer)
+
+	ok := result.New("a", nil)
+	failed := result.New("b.enc", header.ErrWrongPassword)
+	events.Start("encrypt", 2, 4)
+	events.Progress("a", 50)
+	events.Result(ok, "a.enc")
+	events.Result(failed, "b")
+	events.Removed("a", nil)
+	events.Summary([]result.Result{ok, failed}, 1500*time.Millisecond)
+	events.Error(errors.New(esccode.Red+"bad"+esccode.Reset), result.ExitUsage)
+
+	want := []map[string]any{
+		{"event": "start", "command": "encrypt", "files": 2.0, "jobs": 4.0},
+		{"event": "progress", "file": "a", "percent": 50.0},
+		{"event": "result", "file": "a", "output": "a.enc", "status": "ok"},
+		{"event": "result", "file": "b.enc", "status": "auth_failed", "error": header.WrongPasswordErr},
+		{"event": "removed", "file": "a"},
+		{"event": "summary", "files": 2.0, "exit_code": float64(result.ExitPartial), "elapsed_ms": 1500.0, "counts": map[string]any{
+			"ok": 1.0, "skipped": 0.0, "auth_failed": 1.0, "io_error": 0.0, "truncated": 0.0, "corrupted": 0.0, "unsupported": 0.0,
+		}},
+		{"event": "error", "error": "bad", "exit_code": float64(result.ExitUsage)},
+	}
+
+	lines := strings.Split(strings.TrimSuffix(buffer.String(), "\n"), "\n")
+	if len(lines) != len(want) {
+		t.Fatalf("got : %v lines want : %v", len(lines), len(want))
+	}
+	for i, line := range lines {
+		var got map[string]any
+		if err := json.Unmarshal([]byte(line), &got); err != nil {
+			t.Fatalf("line %d is not JSON: %v", i+1, err)
+		}
+		if !reflect.DeepEqual(got, want[i]) {
+			t.Errorf("got : %v want : %v", got, want[i])
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package headertest
+
+import (
+	"bytes"
+	"crypto/ecdh"
+	"crypto/rand"
+	"errors"
+	"testing"
+
+	header "github.com/ShuaibKhan786/cipher-project/internal/header"
+	kdf "github.com/ShuaibKhan786/cipher-project/internal/kdf"
+)
+
+var testParams = kdf.Params{Iteration: 1, Memory: 1024, Thread: 1}
+
+func TestHeader(t *testing.T) {
+	dataKey, err := header.NewDataKey()
+	assertError(t, err)
+
+	salt := make([]byte, header.SaltSize)
+	rand.Read(salt)
+	keys := kdf.NewCache()
+
+	t.Run("testing password slot unlock", func(t *testing.T) {
+		hdr := newHeader(t, dataKey, []byte("first"), salt)
+
+		got, index, err := readBack(t, hdr).Unlock([]byte("first"), nil, keys)
+		assertError(t, err)
+		assertKey(t, got, dataKey)
+		if index != 0 {
+			t.Errorf("got : %v want : %v", index, 0)
+		}
+
+		if _, _, err := hdr.Unlock([]byte("wrong"), nil, kdf.NewCache()); !errors.Is(err, header.ErrWrongPassword) {
+			t.Errorf("got : %v want : %v", err, header.ErrWrongPassword)
+		}
+	})
+
+	t.Run("testing a damaged slot", func(t *testing.T) {
+		hdr := readBack(t, newHeader(t, dataKey, []byte("first"), salt))
+		hdr.Slots[0].WrappedKey[0] ^= 1
+
+		if _, _, err := hdr.Unlock([]byte("first"), nil, kdf.NewCache()); !errors.Is(err, header.ErrSlotDamaged) {
+			t.Errorf("got : %v want : %v", err, header.ErrSlotDamaged)
+		}
+		if _, _, err := hdr.Unlock([]byte("wrong"), nil, kdf.NewCache()); !errors.Is(err, header.ErrWrongPassword) {
+			t.Errorf("got : %v want : %v", err, header.ErrWrongPassword)
+		}
+	})
+
+	t.Run("testing adding and removing slots", func(t *testing.T) {
+		hdr := newHeader(t, dataKey, []byte("first"), salt)
+
+		kek := kdf.IDKey([]byte("second"), salt, testParams)
+		slot, err := header.NewPasswordSlot(dataKey, kek, salt, testParams)
+		assertError(t, err)
+		index, err := hdr.AddSlot(slot)
+		assertError(t, err)
+
+		got, _, err := readBack(t, hdr).Unlock([]byte("second"), nil, kdf.NewCache())
+		assertError(t, err)
+		assertKey(t, got, dataKey)
+
+		assertError(t, hdr.RemoveSlot(0))
+		if err := hdr.RemoveSlot(index); err == nil {
+			t.Errorf("must not remove the last slot")
+		}
+		if _, _, err := readBack(t, hdr).Unlock([]byte("first"), nil, kdf.NewCache()); err == nil {
+			t.Errorf("removed slot must not unlock anymore")
+		}
+	})
+
+	t.Run("testing recipient slot unlock", func(t *testing.T) {
+		identity, err := ecdh.X25519().GenerateKey(rand.Reader)
+		assertError(t, err)
+
+		slot, err := header.NewRecipientSlot(dataKey, identity.PublicKey().Bytes())
+		assertError(t, err)
+		hdr := header.New(make([]byte, header.NonceSize))
+		_, err = hdr.AddSlot(slot)
+		assertError(t, err)
+
+		got, _, err := readBack(t, hdr).UnlockIdentity(identity.Bytes())
+		assertError(t, err)
+		assertKey(t, got, dataKey)
+
+		other, err := ecdh.X25519().GenerateKey(rand.Reader)
+		assertError(t, err)
+		if _, _, err := hdr.UnlockIdentity(other.Bytes()); err == nil {
+			t.Errorf("must not unlock with another private key")
+		}
+	})
+}
+
+func newHeader(t *testing.T, dataKey, password, salt []byte) *header.Header {
+	t.Helper()
+
+	kek := kdf.IDKey(password, salt, testParams)
+	slot, err := header.NewPasswordSlot(dataKey, kek, salt, testParams)
+	assertError(t, err)
+
+	hdr := header.New(make([]byte, header.NonceSize))
+	_, err = hdr.AddSlot(slot)
+	assertError(t, err)
+	return hdr
+}
+
+func readBack(t *testing.T, hdr *header.Header) *header.Header {
+	t.Helper()
+
+	var buffer bytes.Buffer
+	assertError(t, hdr.Write(&buffer))
+	if int64(buffer.Len()) != hdr.Size() {
+		t.Errorf("got : %v want : %v", buffer.Len(), hdr.Size())
+	}
+	got, err := header.Read(&buffer)
+	assertError(t, err)
+	return got
+}
+
+func assertKey(t *testing.T, got, want []byte) {
+	t.Helper()
+	if !bytes.Equal(got, want) {
+		t.Errorf("got : %x want : %x", got, want)
+	}
+}
+
+func assertError(t *testing.T, err error) {
+	t.Helper()
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+}
@@ -0,0 +1,32 @@
+package kdftest
+
+import (
+	"bytes"
+	"testing"
+
+	kdf "github.com/ShuaibKhan786/cipher-project/internal/kdf"
+)
+
+func TestCache(t *testing.T) {
+	params := kdf.Params{Iteration: 1, Memory: 8 * 1024, Thread: 1}
+	salt := bytes.Repeat([]byte{1}, 16)
+	keys := kdf.NewCache()
+
+	first := keys.IDKey([]byte("first"), salt, params)
+	if want := kdf.IDKey([]byte("first"), salt, params); !bytes.Equal(first, want) {
+		t.Fatalf("got : %x want : %x", first, want)
+	}
+	again := keys.IDKey([]byte("first"), salt, params)
+	if &again[0] != &first[0] {
+		t.Errorf("the same password and salt were derived twice")
+	}
+	second := keys.IDKey([]byte("second"), salt, params)
+	if bytes.Equal(second, first) {
+		t.Errorf("two passwords got the same key")
+	}
+	// another cache has its own id key, and finds the same keys
+	if other := kdf.NewCache().IDKey([]byte("first"), salt, params); !bytes.Equal(other, first) {
+		t.Errorf("got : %x want : %x", other, first)
+	}
+	keys.Destroy()
+}
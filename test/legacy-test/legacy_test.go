@@ -0,0 +1,92 @@
+package legacytest
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"errors"
+	"io"
+	"testing"
+
+	aescipher "github.com/ShuaibKhan786/cipher-project/internal/cipher"
+	header "github.com/ShuaibKhan786/cipher-project/internal/header"
+	legacy "github.com/ShuaibKhan786/cipher-project/internal/legacy"
+	secbuf "github.com/ShuaibKhan786/cipher-project/internal/secbuf"
+)
+
+// legacyFile writes plain the way files were encrypted before the
+// header, every chunk under the same nonce.
+func legacyFile(t *testing.T, key, salt, nonce, plain []byte) []byte {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file := append(bytes.Clone(salt), nonce...)
+	for len(plain) > 0 {
+		n := min(len(plain), aescipher.ChunkSize)
+		file = gcm.Seal(file, nonce, plain[:n], nil)
+		plain = plain[n:]
+	}
+	return file
+}
+
+func TestReader(t *testing.T) {
+	key := bytes.Repeat([]byte{7}, 32)
+	salt := bytes.Repeat([]byte{1}, legacy.SaltSize)
+	nonce := bytes.Repeat([]byte{2}, legacy.NonceSize)
+	plain := bytes.Repeat([]byte("EncryptEase"), 200000)
+	file := legacyFile(t, key, salt, nonce, plain)
+
+	read := func(file, key []byte) ([]byte, error) {
+		r := bytes.NewReader(file)
+		gotSalt, gotNonce, err := legacy.ReadHeader(r)
+		if err != nil {
+			return nil, err
+		}
+		if !bytes.Equal(gotSalt, salt) || !bytes.Equal(gotNonce, nonce) {
+			t.Fatalf("got : %x %x want : %x %x", gotSalt, gotNonce, salt, nonce)
+		}
+		reader, err := legacy.NewReader(r, secbuf.From(bytes.Clone(key)), gotNonce)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return io.ReadAll(reader)
+	}
+
+	t.Run("testing a legacy file is read back", func(t *testing.T) {
+		got, err := read(file, key)
+		if err != nil || !bytes.Equal(got, plain) {
+			t.Errorf("got : %d bytes, %v want : %d bytes", len(got), err, len(plain))
+		}
+	})
+
+	t.Run("testing a wrong key", func(t *testing.T) {
+		if _, err := read(file, bytes.Repeat([]byte{8}, 32)); !errors.Is(err, header.ErrWrongPassword) {
+			t.Errorf("got : %v want : %v", err, header.ErrWrongPassword)
+		}
+	})
+
+	t.Run("testing a damaged chunk after the first", func(t *testing.T) {
+		damaged := bytes.Clone(file)
+		damaged[len(damaged)-1] ^= 1
+		_, err := read(damaged, key)
+		var chunkErr *aescipher.ChunkError
+		if !errors.As(err, &chunkErr) || chunkErr.Chunk != 2 || !errors.Is(err, aescipher.ErrAuthentication) {
+			t.Errorf("got : %v want : chunk 2 %v", err, aescipher.ErrAuthentication)
+		}
+	})
+
+	t.Run("testing a file with a header", func(t *testing.T) {
+		current := append([]byte(header.Magic), make([]byte, legacy.HeaderSize)...)
+		if _, _, err := legacy.ReadHeader(bytes.NewReader(current)); !errors.Is(err, legacy.ErrCurrentFormat) {
+			t.Errorf("got : %v want : %v", err, legacy.ErrCurrentFormat)
+		}
+		if _, _, err := legacy.ReadHeader(bytes.NewReader(salt)); !errors.Is(err, header.ErrNotEncrypted) {
+			t.Errorf("got : %v want : %v", err, header.ErrNotEncrypted)
+		}
+	})
+}
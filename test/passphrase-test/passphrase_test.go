@@ -0,0 +1,41 @@
+package passphrasetest
+
+import (
+	"strings"
+	"testing"
+
+	passphrase "github.com/ShuaibKhan786/cipher-project/internal/passphrase"
+	input "github.com/ShuaibKhan786/cipher-project/internal/userinp"
+)
+
+func TestGenerate(t *testing.T) {
+	t.Run("testing the number of words", func(t *testing.T) {
+		for _, n := range []int{passphrase.MinWords, passphrase.DefaultWords, passphrase.MaxWords} {
+			phrase, err := passphrase.Generate(n)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := len(strings.Split(phrase, passphrase.Separator)); got != n {
+				t.Errorf("got : %v words want : %v", got, n)
+			}
+		}
+	})
+
+	t.Run("testing an invalid number of words", func(t *testing.T) {
+		for _, n := range []int{0, passphrase.MinWords - 1, passphrase.MaxWords + 1} {
+			if _, err := passphrase.Generate(n); err == nil {
+				t.Errorf("%v words must return an error", n)
+			}
+		}
+	})
+
+	t.Run("testing the default entropy", func(t *testing.T) {
+		if got := passphrase.Entropy(passphrase.DefaultWords); got < 70 {
+			t.Errorf("got : %.1f bits want : at least 70", got)
+		}
+		phrase, _ := passphrase.Generate(passphrase.DefaultWords)
+		if got := input.EstimateStrength([]byte(phrase)); got.Score < input.GoodScore {
+			t.Errorf("%q got : %v want : at least %v", phrase, got.Score, input.GoodScore)
+		}
+	})
+}
@@ -0,0 +1,74 @@
+package progresstest
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	progress "github.com/ShuaibKhan786/cipher-project/internal/progress"
+)
+
+func TestFormat(t *testing.T) {
+	t.Run("testing byte units", func(t *testing.T) {
+		tests := map[int64]string{
+			512:     "512 B",
+			1536:    "1.5 KiB",
+			5 << 20: "5.0 MiB",
+			3 << 30: "3.0 GiB",
+		}
+		for n, want := range tests {
+			if got := progress.Bytes(n); got != want {
+				t.Errorf("got : %v want : %v", got, want)
+			}
+		}
+	})
+
+	t.Run("testing the time left", func(t *testing.T) {
+		if got := progress.ETA(90<<20, progress.Rate(1<<20, time.Second)); got != "1m30s" {
+			t.Errorf("got : %v want : %v", got, "1m30s")
+		}
+		if got := progress.ETA(1, 0); got != "--" {
+			t.Errorf("got : %v want : %v", got, "--")
+		}
+	})
+
+	t.Run("testing a long name cut in the middle", func(t *testing.T) {
+		got := progress.Shorten("documents/2024/report-final.pdf", 15)
+		if got != "documen…nal.pdf" {
+			t.Errorf("got : %v want : %v", got, "documen…nal.pdf")
+		}
+	})
+}
+
+func TestRenderer(t *testing.T) {
+	dir := t.TempDir()
+	var filenames []string
+	for _, name := range []string{"a", "b"} {
+		filename := filepath.Join(dir, name)
+		if err := os.WriteFile(filename, make([]byte, 2048), 0600); err != nil {
+			t.Fatal(err)
+		}
+		filenames = append(filenames, filename)
+	}
+
+	var buffer bytes.Buffer
+	r := progress.New(&buffer, filenames)
+	r.Update(filenames[0], 1024, 2048)
+	r.Finish(filenames[0])
+	r.Finish(filenames[1])
+	r.Close()
+
+	lines := strings.Split(strings.TrimSuffix(buffer.String(), "\n"), "\n")
+	if strings.Contains(buffer.String(), "\033") {
+		t.Errorf("escape codes in plain output: %q", buffer.String())
+	}
+	if !strings.HasPrefix(lines[0], "0/2 files  1.0 KiB/4.0 KiB") {
+		t.Errorf("got : %v want the first update", lines[0])
+	}
+	if last := lines[len(lines)-1]; !strings.HasPrefix(last, "2/2 files  4.0 KiB/4.0 KiB") {
+		t.Errorf("got : %v want the final state", last)
+	}
+}
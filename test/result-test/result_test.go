@@ -0,0 +1,63 @@
+package resulttest
+
+import (
+	"errors"
+	"os"
+	"testing"
+
+	aescipher "github.com/ShuaibKhan786/cipher-project/internal/cipher"
+	header "github.com/ShuaibKhan786/cipher-project/internal/header"
+	result "github.com/ShuaibKhan786/cipher-project/internal/result"
+	salting "github.com/ShuaibKhan786/cipher-project/internal/salting"
+)
+
+func TestResult(t *testing.T) {
+	t.Run("testing the status of an error", func(t *testing.T) {
+		_, notFound := os.Open("missing")
+		cases := []struct {
+			err  error
+			want result.Status
+		}{
+			{nil, result.OK},
+			{result.Skip(errors.New("vault")), result.Skipped},
+			{&os.PathError{Op: "unlock", Path: "file", Err: header.ErrWrongPassword}, result.AuthFailed},
+			{salting.SaltMismatch("file"), result.AuthFailed},
+			{&os.PathError{Op: "decrypt", Path: "file", Err: aescipher.ErrTruncated}, result.Truncated},
+			{header.ErrNotEncrypted, result.Unsupported},
+			{&os.PathError{Op: "read header", Path: "file", Err: header.ErrUnsupportedVersion}, result.Unsupported},
+			{&aescipher.ChunkError{Chunk: 3, Err: aescipher.ErrAuthentication}, result.Corrupted},
+			{notFound, result.IOError},
+		}
+		for _, c := range cases {
+			if got := result.New("file", c.err).Status; got != c.want {
+				t.Errorf("%v: got : %v want : %v", c.err, got, c.want)
+			}
+		}
+	})
+
+	t.Run("testing the exit code of a batch", func(t *testing.T) {
+		ok := result.Result{Status: result.OK}
+		skipped := result.Result{Status: result.Skipped}
+		auth := result.Result{Status: result.AuthFailed}
+		io := result.Result{Status: result.IOError}
+		corrupted := result.Result{Status: result.Corrupted}
+		unsupported := result.Result{Status: result.Unsupported}
+		cases := []struct {
+			results []result.Result
+			want    int
+		}{
+			{[]result.Result{ok, skipped}, result.ExitOK},
+			{[]result.Result{ok, auth}, result.ExitPartial},
+			{[]result.Result{auth, auth, skipped}, result.ExitWrongPassword},
+			{[]result.Result{auth, io}, result.ExitFailure},
+			{[]result.Result{auth, corrupted}, result.ExitFailure},
+			{[]result.Result{unsupported}, result.ExitFailure},
+			{[]result.Result{ok, unsupported}, result.ExitPartial},
+		}
+		for i, c := range cases {
+			if got := result.ExitCode(c.results); got != c.want {
+				t.Errorf("case %d: got : %v want : %v", i, got, c.want)
+			}
+		}
+	})
+}
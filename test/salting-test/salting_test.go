@@ -1,67 +1,67 @@
 package saltingtest
 
 import (
+	"errors"
 	"os"
 	"reflect"
 	"testing"
 
 	cliarg "github.com/ShuaibKhan786/cipher-project/internal/cmdlineargs"
+	header "github.com/ShuaibKhan786/cipher-project/internal/header"
 	salting "github.com/ShuaibKhan786/cipher-project/internal/salting"
 )
 
 func TestSalting(t *testing.T) {
 	md := cliarg.NewArgsMetaData()
 	md.Operation = cliarg.EncryptionOp
-	md.FileNames = []string {"test1.salt","test2.salt","test3.salt"}
+	md.FileNames = []string{"test1.salt", "test2.salt", "test3.salt"}
 	md.NumOfFiles = 3
 
-	pair := salting.NewSaltNoncePair(16,12,md.NumOfFiles)
+	pair := salting.NewSaltNoncePair(16, 12, md.NumOfFiles)
 
-	err := pair.GenerateSaltNoncePair(&md)
+	err := pair.GenerateSaltNoncePair()
 
-	t.Run("testing salt generation for encryption operation",func(t *testing.T) {
+	t.Run("testing salt generation for encryption operation", func(t *testing.T) {
 
-		assertError(t,err)
+		assertError(t, err)
 
 		if len(pair.S) != 16 {
-			t.Errorf("got : %v want : %v",len(pair.S),16)
+			t.Errorf("got : %v want : %v", len(pair.S), 16)
 		}
 		if len(pair.NN) != md.NumOfFiles {
-			t.Errorf("got : %v want : %v",len(pair.NN),md.NumOfFiles)
+			t.Errorf("got : %v want : %v", len(pair.NN), md.NumOfFiles)
 		}
 		for _, v := range pair.NN {
 			if len(v) != 12 {
-				t.Errorf("got : %v want : %v",len(v),12)
+				t.Errorf("got : %v want : %v", len(v), 12)
 			}
 		}
 	})
 
-	t.Run("testing salt verification for decryption operation", func(t *testing.T) {
-		err := testFakeFile(&md,pair)
-		if err != nil {
-			t.Errorf(err.Error())
-		}
-
-		defer testFakeFileRem(md.FileNames)
+	t.Run("testing key groups of files with different salts", func(t *testing.T) {
+		other := salting.NewSaltNoncePair(16, 12, 1)
+		assertError(t, other.GenerateSaltNoncePair())
+		filenames := []string{"test1.salt", "test2.salt", "test3.salt", "test4.salt"}
 
-		mdDe := cliarg.NewArgsMetaData()
-		mdDe.Operation = cliarg.DecryptionOp
-		mdDe.FileNames = md.FileNames
-		mdDe.NumOfFiles = md.NumOfFiles
+		assertError(t, testFakeFile(&md, pair))
+		assertError(t, testFakeFile(&cliarg.ArgsMetaData{FileNames: filenames[3:]}, other))
+		defer testFakeFileRem(filenames)
 
-		pairDe := salting.NewSaltNoncePair(16,12,mdDe.NumOfFiles)
-
-		err = pairDe.GenerateSaltNoncePair(&mdDe)
-
-		assertError(t,err)
+		groups := salting.GroupByKeyParams(append(filenames, "missing.salt"))
+		want := []salting.KeyGroup{{FileNames: filenames[:3]}, {FileNames: filenames[3:]}}
+		if !reflect.DeepEqual(groups, want) {
+			t.Errorf("got : %v want : %v", groups, want)
+		}
 
-		if !reflect.DeepEqual(pair,pairDe) {
-			t.Errorf("got : %x and want %x",pairDe,pair)
+		err := salting.SaltMismatch(groups[1].FileNames[0])
+		var pathErr *os.PathError
+		if !errors.Is(err, salting.ErrSaltMismatch) || !errors.As(err, &pathErr) || pathErr.Path != "test4.salt" {
+			t.Errorf("got : %v want : %v naming test4.salt", err, salting.ErrSaltMismatch)
 		}
 	})
 }
 
-func testFakeFile(md *cliarg.ArgsMetaData,pair *salting.SaltNoncePair) error {
+func testFakeFile(md *cliarg.ArgsMetaData, pair *salting.SaltNoncePair) error {
 	for i, v := range md.FileNames {
 		file, err := os.Create(v)
 		if err != nil {
@@ -69,8 +69,10 @@ func testFakeFile(md *cliarg.ArgsMetaData,pair *salting.SaltNoncePair) error {
 			testFakeFileRem(md.FileNames[:i])
 			return err
 		}
-		file.Write(pair.S)
-		file.Write(pair.NN[i])
+		slot := header.Slot{Type: header.SlotPassword, Salt: pair.S}
+		hdr := header.New(pair.NN[i])
+		hdr.AddSlot(slot)
+		hdr.Write(file)
 		file.Close()
 	}
 	return nil
@@ -82,7 +84,7 @@ func testFakeFileRem(fileNames []string) {
 	}
 }
 
-func assertError(t *testing.T,err error) {
+func assertError(t *testing.T, err error) {
 	t.Helper()
 	if err != nil {
 		t.Errorf("expected no error, but got %v", err)
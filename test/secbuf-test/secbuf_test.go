@@ -0,0 +1,39 @@
+package secbuftest
+
+import (
+	"bytes"
+	"testing"
+
+	secbuf "github.com/ShuaibKhan786/cipher-project/internal/secbuf"
+)
+
+func TestBuffer(t *testing.T) {
+	t.Run("testing that the source is wiped", func(t *testing.T) {
+		src := []byte("correct horse battery staple")
+		want := bytes.Clone(src)
+
+		buf := secbuf.From(src)
+		defer buf.Destroy()
+		if !bytes.Equal(buf.Bytes(), want) {
+			t.Errorf("got : %q want : %q", buf.Bytes(), want)
+		}
+		if !bytes.Equal(src, make([]byte, len(src))) {
+			t.Errorf("the source must be zeroed, got : %q", src)
+		}
+	})
+
+	t.Run("testing destroy", func(t *testing.T) {
+		buf := secbuf.From([]byte("secret key"))
+		buf.Destroy()
+		if buf.Bytes() != nil || buf.Len() != 0 {
+			t.Errorf("a destroyed buffer must be empty")
+		}
+		// destroying twice, or a nil buffer, is a no-op
+		buf.Destroy()
+		var missing *secbuf.Buffer
+		missing.Destroy()
+		if missing.Bytes() != nil {
+			t.Errorf("a nil buffer must be empty")
+		}
+	})
+}
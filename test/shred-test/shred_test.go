@@ -0,0 +1,126 @@
+package shredtest
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	shred "github.com/ShuaibKhan786/cipher-project/internal/shred"
+)
+
+func TestFile(t *testing.T) {
+	dir := t.TempDir()
+
+	t.Run("testing a file is overwritten in place and removed", func(t *testing.T) {
+		path := filepath.Join(dir, "secret")
+		if err := os.WriteFile(path, make([]byte, 100*1024), 0600); err != nil {
+			t.Fatal(err)
+		}
+		// a hard link shows what became of the blocks of the file
+		link := filepath.Join(dir, "link")
+		if err := os.Link(path, link); err != nil {
+			t.Skip("no hard links here:", err)
+		}
+		if err := shred.File(path, 2); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := os.Lstat(path); !errors.Is(err, os.ErrNotExist) {
+			t.Errorf("the file is still there: %v", err)
+		}
+		info, err := os.Stat(link)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Size() != 0 {
+			t.Errorf("the file was not truncated, size %d", info.Size())
+		}
+	})
+
+	t.Run("testing every pass overwrites the whole file", func(t *testing.T) {
+		const size = 150 * 1024
+		target := &recorder{data: make([]byte, size)}
+		if err := shred.Overwrite(target, size, 3); err != nil {
+			t.Fatal(err)
+		}
+		// a sync after every pass, then one after the truncation
+		if len(target.synced) != 4 {
+			t.Fatalf("got : %d syncs want : %d", len(target.synced), 4)
+		}
+		before := make([]byte, size)
+		for pass, data := range target.synced[:3] {
+			if len(data) != size {
+				t.Fatalf("pass %d got : %d bytes want : %d", pass, len(data), size)
+			}
+			// random data never leaves a block as it was
+			for i := 0; i < size; i += 1024 {
+				if bytes.Equal(data[i:i+1024], before[i:i+1024]) {
+					t.Errorf("pass %d left the block at %d as it was", pass, i)
+				}
+			}
+			before = data
+		}
+		if len(target.synced[3]) != 0 {
+			t.Errorf("the file was not truncated, size %d", len(target.synced[3]))
+		}
+	})
+
+	t.Run("testing a symbolic link is refused", func(t *testing.T) {
+		target := filepath.Join(dir, "target")
+		if err := os.WriteFile(target, []byte("keep me"), 0600); err != nil {
+			t.Fatal(err)
+		}
+		symlink := filepath.Join(dir, "symlink")
+		if err := os.Symlink(target, symlink); err != nil {
+			t.Fatal(err)
+		}
+		if err := shred.File(symlink, 1); err == nil {
+			t.Fatal("a symbolic link was shredded")
+		}
+		if data, err := os.ReadFile(target); err != nil || string(data) != "keep me" {
+			t.Errorf("the target changed: %q, %v", data, err)
+		}
+	})
+
+	t.Run("testing a missing file", func(t *testing.T) {
+		if err := shred.File(filepath.Join(dir, "missing"), 1); !errors.Is(err, os.ErrNotExist) {
+			t.Errorf("got %v", err)
+		}
+	})
+}
+
+// recorder is a file in memory keeping its contents at every sync.
+type recorder struct {
+	data   []byte
+	offset int64
+	synced [][]byte
+}
+
+func (r *recorder) Write(p []byte) (int, error) {
+	if end := r.offset + int64(len(p)); end > int64(len(r.data)) {
+		r.data = append(r.data, make([]byte, end-int64(len(r.data)))...)
+	}
+	n := copy(r.data[r.offset:], p)
+	r.offset += int64(n)
+	return n, nil
+}
+
+func (r *recorder) Seek(offset int64, whence int) (int64, error) {
+	if whence != io.SeekStart {
+		return 0, errors.New("only seeking from the start is supported")
+	}
+	r.offset = offset
+	return offset, nil
+}
+
+func (r *recorder) Sync() error {
+	r.synced = append(r.synced, bytes.Clone(r.data))
+	return nil
+}
+
+func (r *recorder) Truncate(size int64) error {
+	r.data = r.data[:size]
+	return nil
+}
@@ -0,0 +1,30 @@
+package userinptest
+
+import (
+	"testing"
+
+	input "github.com/ShuaibKhan786/cipher-project/internal/userinp"
+)
+
+func TestPasswordStrength(t *testing.T) {
+	t.Run("testing weak passwords", func(t *testing.T) {
+		for _, pw := range []string{"", "password", "P@ssw0rd", "123456", "qwerty123", "aaaaaaaaaaaa", "abcabcabcabc", "monkey2019"} {
+			got := input.EstimateStrength([]byte(pw))
+			if got.Score >= input.FairScore {
+				t.Errorf("%q got : %v want : below %v", pw, got.Score, input.FairScore)
+			}
+			if len(got.Warnings) == 0 {
+				t.Errorf("%q must come with a warning", pw)
+			}
+		}
+	})
+
+	t.Run("testing strong passwords", func(t *testing.T) {
+		for _, pw := range []string{"x7#Kq9!mZ2pL", "kT9pWq2zR4vN8sYb", "mellow-gravel-oyster-tundra-fabric"} {
+			got := input.EstimateStrength([]byte(pw))
+			if got.Score < input.GoodScore {
+				t.Errorf("%q got : %v want : at least %v", pw, got.Score, input.GoodScore)
+			}
+		}
+	})
+}
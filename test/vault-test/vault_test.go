@@ -0,0 +1,270 @@
+package vaulttest
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	cipher "github.com/ShuaibKhan786/cipher-project/internal/cipher"
+	header "github.com/ShuaibKhan786/cipher-project/internal/header"
+	kdf "github.com/ShuaibKhan786/cipher-project/internal/kdf"
+	result "github.com/ShuaibKhan786/cipher-project/internal/result"
+	secbuf "github.com/ShuaibKhan786/cipher-project/internal/secbuf"
+	vault "github.com/ShuaibKhan786/cipher-project/internal/vault"
+)
+
+var testParams = kdf.Params{Iteration: 1, Memory: 64, Thread: 1}
+
+func TestVault(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "test.enc")
+	hdr, key := newHeader(t)
+
+	v, err := vault.Create(name, hdr, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v.Close()
+
+	t.Run("testing add and list after reopening", func(t *testing.T) {
+		v := open(t, name, key)
+		add(t, v, "first.txt", "first content")
+		add(t, v, "dir/second.txt", strings.Repeat("second", 1000))
+		if err := v.Commit(); err != nil {
+			t.Fatal(err)
+		}
+		v.Close()
+
+		v = open(t, name, key)
+		defer v.Close()
+		assertNames(t, v, "dir/second.txt", "first.txt")
+		if err := v.Add("first.txt", strings.NewReader("again"), fileInfo(t)); err == nil {
+			t.Errorf("adding an existing name must return an error")
+		}
+	})
+
+	t.Run("testing extract", func(t *testing.T) {
+		v := open(t, name, key)
+		defer v.Close()
+		var got bytes.Buffer
+		if err := v.Extract("first.txt", &got); err != nil {
+			t.Fatal(err)
+		}
+		if got.String() != "first content" {
+			t.Errorf("got : %q want : %q", got.String(), "first content")
+		}
+		if err := v.Extract("missing", &got); err == nil {
+			t.Errorf("extracting a missing entry must return an error")
+		}
+	})
+
+	t.Run("testing extract from a damaged vault", func(t *testing.T) {
+		damaged := filepath.Join(t.TempDir(), "damaged.enc")
+		dhdr, dkey := newHeader(t)
+		v, err := vault.Create(damaged, dhdr, dkey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		add(t, v, "entry.txt", "content to damage")
+		if err := v.Commit(); err != nil {
+			t.Fatal(err)
+		}
+		entry := v.Entries()[0]
+		v.Close()
+
+		file, err := os.OpenFile(damaged, os.O_RDWR, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		file.WriteAt([]byte{0xff}, entry.Offset)
+		file.Close()
+
+		v = open(t, damaged, dkey)
+		defer v.Close()
+		err = v.Extract("entry.txt", &bytes.Buffer{})
+		if !errors.Is(err, cipher.ErrAuthentication) {
+			t.Fatalf("got : %v want : %v", err, cipher.ErrAuthentication)
+		}
+		// wrapped with the entry name the way the vault command does
+		results := []result.Result{result.New(damaged, &os.PathError{Op: "vault extract", Path: entry.Name, Err: err})}
+		if results[0].Status != result.Corrupted {
+			t.Errorf("got : %v want : %v", results[0].Status, result.Corrupted)
+		}
+		if code := result.ExitCode(results); code != result.ExitFailure {
+			t.Errorf("got : exit %d want : exit %d", code, result.ExitFailure)
+		}
+	})
+
+	t.Run("testing a vault cut back to an earlier commit", func(t *testing.T) {
+		cut := filepath.Join(t.TempDir(), "cut.enc")
+		chdr, ckey := newHeader(t)
+		v, err := vault.Create(cut, chdr, ckey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		add(t, v, "removed.txt", "removed content")
+		if err := v.Commit(); err != nil {
+			t.Fatal(err)
+		}
+		info, err := os.Stat(cut)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := v.Remove("removed.txt"); err != nil {
+			t.Fatal(err)
+		}
+		if err := v.Commit(); err != nil {
+			t.Fatal(err)
+		}
+		v.Close()
+
+		// the older index is still in the file, it must not come back
+		if err := os.Truncate(cut, info.Size()); err != nil {
+			t.Fatal(err)
+		}
+		hdr, err := header.ReadFile(cut)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := vault.Open(cut, hdr, ckey); !errors.Is(err, cipher.ErrAuthentication) {
+			t.Errorf("got : %v want : %v", err, cipher.ErrAuthentication)
+		}
+	})
+
+	t.Run("testing a commit interrupted before the header", func(t *testing.T) {
+		interrupted := filepath.Join(t.TempDir(), "interrupted.enc")
+		ihdr, ikey := newHeader(t)
+		v, err := vault.Create(interrupted, ihdr, ikey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		add(t, v, "kept.txt", "kept content")
+		if err := v.Commit(); err != nil {
+			t.Fatal(err)
+		}
+		info, err := os.Stat(interrupted)
+		if err != nil {
+			t.Fatal(err)
+		}
+		oldHeader := make([]byte, ihdr.Size())
+		file, err := os.OpenFile(interrupted, os.O_RDWR, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer file.Close()
+		if _, err := file.ReadAt(oldHeader, 0); err != nil {
+			t.Fatal(err)
+		}
+		add(t, v, "lost.txt", "lost content")
+		if err := v.Commit(); err != nil {
+			t.Fatal(err)
+		}
+		v.Close()
+
+		// the index and trailer of the second commit are written but
+		// its nonce never reached the header
+		if _, err := file.WriteAt(oldHeader, 0); err != nil {
+			t.Fatal(err)
+		}
+		v = open(t, interrupted, ikey)
+		defer v.Close()
+		assertNames(t, v, "kept.txt")
+		if got, err := os.Stat(interrupted); err != nil || got.Size() != info.Size() {
+			t.Errorf("the interrupted commit must be dropped, got : %v bytes want : %v", got.Size(), info.Size())
+		}
+		add(t, v, "after.txt", "after content")
+		if err := v.Commit(); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("testing uncommitted changes", func(t *testing.T) {
+		v := open(t, name, key)
+		add(t, v, "dropped.txt", "never committed")
+		v.Close()
+
+		v = open(t, name, key)
+		defer v.Close()
+		assertNames(t, v, "dir/second.txt", "first.txt")
+	})
+
+	t.Run("testing remove and compact", func(t *testing.T) {
+		v := open(t, name, key)
+		if err := v.Remove("dir/second.txt"); err != nil {
+			t.Fatal(err)
+		}
+		if err := v.Compact(); err != nil {
+			t.Fatal(err)
+		}
+		if v.Garbage() != 0 {
+			t.Errorf("got : %v bytes of garbage want : 0", v.Garbage())
+		}
+		v.Close()
+
+		v = open(t, name, key)
+		defer v.Close()
+		assertNames(t, v, "first.txt")
+		var got bytes.Buffer
+		if err := v.Extract("first.txt", &got); err != nil || got.String() != "first content" {
+			t.Errorf("the remaining entry must survive compaction, got : %q %v", got.String(), err)
+		}
+	})
+}
+
+func newHeader(t *testing.T) (*header.Header, *secbuf.Buffer) {
+	t.Helper()
+	dataKey, err := header.NewDataKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	salt := []byte("9DFA18BB1E473CD9")
+	slot, err := header.NewPasswordSlot(dataKey, kdf.IDKey([]byte("password"), salt, testParams), salt, testParams)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hdr := header.New([]byte("6A8B1D4E372A"))
+	hdr.AddSlot(slot)
+	return hdr, secbuf.From(dataKey)
+}
+
+func open(t *testing.T, name string, key *secbuf.Buffer) *vault.Vault {
+	t.Helper()
+	hdr, err := header.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, err := vault.Open(name, hdr, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+func add(t *testing.T, v *vault.Vault, name, content string) {
+	t.Helper()
+	if err := v.Add(name, strings.NewReader(content), fileInfo(t)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func fileInfo(t *testing.T) os.FileInfo {
+	t.Helper()
+	info, err := os.Stat(".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return info
+}
+
+func assertNames(t *testing.T, v *vault.Vault, want ...string) {
+	t.Helper()
+	var got []string
+	for _, entry := range v.Entries() {
+		got = append(got, entry.Name)
+	}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("got : %v want : %v", got, want)
+	}
+}
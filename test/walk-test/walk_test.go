@@ -0,0 +1,103 @@
+package walktest
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	walk "github.com/ShuaibKhan786/cipher-project/internal/walk"
+)
+
+func TestFiles(t *testing.T) {
+	root := t.TempDir()
+	for _, name := range []string{"a.txt", "b.txt.enc", "sub/c.txt", ".hidden/d.txt"} {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(name), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink(filepath.Join(root, "a.txt"), filepath.Join(root, "sub", "link.txt")); err != nil {
+		t.Fatal(err)
+	}
+	plain := func(name string) bool { return !strings.HasSuffix(name, ".enc") }
+	base := filepath.Base(root)
+
+	t.Run("testing the default policy", func(t *testing.T) {
+		got := rels(t, root, walk.DefaultPolicy, plain)
+		want := []string{".hidden/d.txt", "a.txt", "sub/c.txt"}
+		assertRels(t, got, base, want)
+	})
+
+	t.Run("testing hidden files and symbolic links", func(t *testing.T) {
+		p := walk.Policy{Symlinks: walk.SymlinkFollow, Special: walk.SpecialSkip, Hidden: walk.HiddenSkip}
+		got := rels(t, root, p, plain)
+		want := []string{"a.txt", "sub/c.txt", "sub/link.txt"}
+		assertRels(t, got, base, want)
+
+		p.Symlinks = walk.SymlinkError
+		if _, err := walk.Files([]string{root}, p, plain); err == nil {
+			t.Errorf("a symbolic link must return an error")
+		}
+	})
+
+	t.Run("testing include and exclude patterns", func(t *testing.T) {
+		p := walk.DefaultPolicy
+		p.Exclude = []string{".hidden"}
+		got := rels(t, root, p, plain)
+		want := []string{"a.txt", "sub/c.txt"}
+		assertRels(t, got, base, want)
+
+		p = walk.DefaultPolicy
+		p.Include = []string{"sub/*", "*.enc"}
+		got = rels(t, root, p, nil)
+		want = []string{"b.txt.enc", "sub/c.txt"}
+		assertRels(t, got, base, want)
+
+		p.Include = []string{"["}
+		if err := p.ValidPatterns(); err == nil {
+			t.Errorf("a malformed pattern must return an error")
+		}
+	})
+
+	t.Run("testing a symbolic link loop", func(t *testing.T) {
+		loop := filepath.Join(root, "sub", "loop")
+		if err := os.Symlink("..", loop); err != nil {
+			t.Fatal(err)
+		}
+		defer os.Remove(loop)
+
+		p := walk.DefaultPolicy
+		p.Symlinks = walk.SymlinkFollow
+		if _, err := walk.Files([]string{root}, p, plain); err == nil {
+			t.Errorf("a loop must return an error")
+		}
+	})
+}
+
+func rels(t *testing.T, root string, p walk.Policy, keep func(string) bool) []string {
+	t.Helper()
+	entries, err := walk.Files([]string{root}, p, keep)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, entry := range entries {
+		got = append(got, filepath.ToSlash(entry.Rel))
+	}
+	return got
+}
+
+func assertRels(t *testing.T, got []string, base string, want []string) {
+	t.Helper()
+	for i := range want {
+		want[i] = base + "/" + want[i]
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got : %v want : %v", got, want)
+	}
+}